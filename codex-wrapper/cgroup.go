@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is the parent directory under which per-task transient cgroups
+// are created, both for the v2 unified hierarchy and each v1 subsystem.
+const cgroupRoot = "codex-wrapper"
+
+// cgroupV1Subsystems are the v1 hierarchies read back for resource metrics.
+// pids has no historical "peak" file in v1, so PeakPIDs stays 0 on that path.
+var cgroupV1Subsystems = []string{"cpuacct", "memory", "pids"}
+
+var nonCgroupNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// cgroupHandle tracks a transient cgroup created for a single task's codex
+// subprocess so its resource usage can be read back after it exits. A nil
+// *cgroupHandle is always a valid, inert no-op.
+type cgroupHandle struct {
+	v2Path  string            // cgroup v2 directory, set when using the unified hierarchy
+	v1Paths map[string]string // subsystem -> directory, set when falling back to v1
+}
+
+// cgroupStats is the resource usage read back from a cgroupHandle after its
+// process has exited.
+type cgroupStats struct {
+	CPUTimeMs       int64
+	PeakMemoryBytes int64
+	PeakPIDs        int64
+}
+
+// newCgroupForTask creates a transient cgroup for taskSpec's subprocess,
+// named after its task ID and pid to keep concurrent/retried runs of the
+// same task ID from colliding. It must be called before the lifetime of the
+// returned handle ends with cleanup(). On non-Linux platforms, or when
+// cgroup creation fails for any reason (no permissions, not mounted, etc.),
+// it returns nil and logs a single warning — resource accounting is
+// best-effort and must never fail task execution.
+func newCgroupForTask(taskSpec TaskSpec, pid int, logWarnFn func(string)) *cgroupHandle {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s-%d", sanitizeCgroupName(taskSpec.ID), pid)
+
+	if h := newCgroupV2(name); h != nil {
+		return h
+	}
+	if h := newCgroupV1(name); h != nil {
+		return h
+	}
+
+	if logWarnFn != nil {
+		logWarnFn("cgroup accounting unavailable, skipping per-task resource metrics")
+	}
+	return nil
+}
+
+func sanitizeCgroupName(id string) string {
+	if id == "" {
+		id = "task"
+	}
+	return nonCgroupNameChars.ReplaceAllString(id, "_")
+}
+
+func newCgroupV2(name string) *cgroupHandle {
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err != nil {
+		return nil
+	}
+	dir := filepath.Join("/sys/fs/cgroup", cgroupRoot, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil
+	}
+	return &cgroupHandle{v2Path: dir}
+}
+
+func newCgroupV1(name string) *cgroupHandle {
+	paths := make(map[string]string, len(cgroupV1Subsystems))
+	for _, sub := range cgroupV1Subsystems {
+		dir := filepath.Join("/sys/fs/cgroup", sub, cgroupRoot, name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			for _, created := range paths {
+				os.Remove(created)
+			}
+			return nil
+		}
+		paths[sub] = dir
+	}
+	return &cgroupHandle{v1Paths: paths}
+}
+
+// addPID moves pid into the cgroup. Called right after cmd.Start(), once
+// the child's real PID is known.
+func (h *cgroupHandle) addPID(pid int) {
+	if h == nil {
+		return
+	}
+	data := []byte(strconv.Itoa(pid) + "\n")
+	if h.v2Path != "" {
+		_ = os.WriteFile(filepath.Join(h.v2Path, "cgroup.procs"), data, 0o644)
+		return
+	}
+	for _, dir := range h.v1Paths {
+		_ = os.WriteFile(filepath.Join(dir, "cgroup.procs"), data, 0o644)
+	}
+}
+
+// readStats reads back resource usage. Called after cmd.Wait() returns, so
+// every field reflects the process's final, full lifetime.
+func (h *cgroupHandle) readStats() cgroupStats {
+	if h == nil {
+		return cgroupStats{}
+	}
+	if h.v2Path != "" {
+		return cgroupStats{
+			CPUTimeMs:       readCPUStatUsec(filepath.Join(h.v2Path, "cpu.stat")) / 1000,
+			PeakMemoryBytes: readCgroupInt(filepath.Join(h.v2Path, "memory.peak")),
+			PeakPIDs:        readCgroupInt(filepath.Join(h.v2Path, "pids.peak")),
+		}
+	}
+	return cgroupStats{
+		CPUTimeMs:       readCgroupInt(filepath.Join(h.v1Paths["cpuacct"], "cpuacct.usage")) / 1_000_000,
+		PeakMemoryBytes: readCgroupInt(filepath.Join(h.v1Paths["memory"], "memory.max_usage_in_bytes")),
+	}
+}
+
+// cleanup removes the cgroup directory/directories. The process must have
+// already exited (cgroupfs refuses to rmdir a non-empty cgroup), which
+// holds for every caller here since it always runs after cmd.Wait().
+func (h *cgroupHandle) cleanup() {
+	if h == nil {
+		return
+	}
+	if h.v2Path != "" {
+		_ = os.Remove(h.v2Path)
+		return
+	}
+	for _, dir := range h.v1Paths {
+		_ = os.Remove(dir)
+	}
+}
+
+// readCgroupInt parses a cgroup file containing a single integer, treating
+// the v2 "max" sentinel (and any unreadable/malformed file) as 0.
+func readCgroupInt(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	s := strings.TrimSpace(string(data))
+	if s == "" || s == "max" {
+		return 0
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// readCPUStatUsec extracts usage_usec from a cgroup v2 cpu.stat file.
+func readCPUStatUsec(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			if v, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return v
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSanitizeCgroupName(t *testing.T) {
+	cases := map[string]string{
+		"":             "task",
+		"build":        "build",
+		"build/step 1": "build_step_1",
+		"a.b-c_d":      "a.b-c_d",
+	}
+	for in, want := range cases {
+		if got := sanitizeCgroupName(in); got != want {
+			t.Errorf("sanitizeCgroupName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReadCgroupInt(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return path
+	}
+
+	if v := readCgroupInt(write("value", "1048576\n")); v != 1048576 {
+		t.Errorf("expected 1048576, got %d", v)
+	}
+	if v := readCgroupInt(write("max", "max\n")); v != 0 {
+		t.Errorf("expected 0 for max sentinel, got %d", v)
+	}
+	if v := readCgroupInt(filepath.Join(dir, "missing")); v != 0 {
+		t.Errorf("expected 0 for missing file, got %d", v)
+	}
+}
+
+func TestReadCPUStatUsec(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cpu.stat")
+	content := "usage_usec 123456\nuser_usec 100000\nsystem_usec 23456\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write cpu.stat: %v", err)
+	}
+
+	if v := readCPUStatUsec(path); v != 123456 {
+		t.Errorf("expected 123456, got %d", v)
+	}
+	if v := readCPUStatUsec(filepath.Join(dir, "missing")); v != 0 {
+		t.Errorf("expected 0 for missing file, got %d", v)
+	}
+}
+
+func TestCgroupHandle_NilIsInert(t *testing.T) {
+	var h *cgroupHandle
+	h.addPID(1234)
+	if stats := h.readStats(); stats != (cgroupStats{}) {
+		t.Errorf("expected zero stats from nil handle, got %+v", stats)
+	}
+	h.cleanup()
+}
+
+func TestNewCgroupForTask_NeverFailsTask(t *testing.T) {
+	var warned string
+	h := newCgroupForTask(TaskSpec{ID: "probe"}, os.Getpid(), func(msg string) { warned = msg })
+	defer h.cleanup()
+
+	if h == nil && warned == "" {
+		t.Fatalf("expected a warning when cgroup creation is unavailable")
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Monkey is a single chaos fault injected into runCodexTaskExec at one of
+// its execution boundaries. Every method has a no-op default via
+// noopMonkey, so a concrete fault need only override the one hook it cares
+// about. --chaos (or CODEX_WRAPPER_CHAOS) parses a spec into a Chaos set
+// and runCodexTaskExec calls every hook unconditionally; with no faults
+// configured, Chaos is a nil slice and every hook is free.
+type Monkey interface {
+	// BeforeStart runs just before cmd.Start(), e.g. to sleep (delay=500ms).
+	// It returns early if ctx is done first.
+	BeforeStart(ctx context.Context)
+
+	// WrapStdout lets a fault rate-limit or otherwise transform the
+	// subprocess's stdout before parseJSONStreamWithLog sees it
+	// (stdout-throttle=10KB/s, drop-lines=5%).
+	WrapStdout(r io.Reader) io.Reader
+
+	// AfterStart runs once cmd has a live PID. A non-nil returned stop func
+	// is deferred by the caller (kill-after=30s schedules a delayed SIGTERM
+	// and cancels it once the caller's own cleanup runs).
+	AfterStart(cmd *exec.Cmd) (stop func())
+
+	// ShortCircuit lets a fault return a TaskResult without ever starting
+	// codex (fail-rate=10% returning exit 137).
+	ShortCircuit() (TaskResult, bool)
+}
+
+// noopMonkey gives every Monkey implementation a free default for the hooks
+// it doesn't use.
+type noopMonkey struct{}
+
+func (noopMonkey) BeforeStart(context.Context)      {}
+func (noopMonkey) WrapStdout(r io.Reader) io.Reader { return r }
+func (noopMonkey) AfterStart(*exec.Cmd) (stop func()) {
+	return nil
+}
+func (noopMonkey) ShortCircuit() (TaskResult, bool) { return TaskResult{}, false }
+
+// Chaos is the set of Monkeys parsed from one --chaos/CODEX_WRAPPER_CHAOS
+// spec. A nil Chaos behaves exactly like a single noopMonkey.
+type Chaos []Monkey
+
+func (c Chaos) BeforeStart(ctx context.Context) {
+	for _, m := range c {
+		m.BeforeStart(ctx)
+	}
+}
+
+func (c Chaos) WrapStdout(r io.Reader) io.Reader {
+	for _, m := range c {
+		r = m.WrapStdout(r)
+	}
+	return r
+}
+
+func (c Chaos) AfterStart(cmd *exec.Cmd) (stop func()) {
+	var stops []func()
+	for _, m := range c {
+		if s := m.AfterStart(cmd); s != nil {
+			stops = append(stops, s)
+		}
+	}
+	if len(stops) == 0 {
+		return nil
+	}
+	return func() {
+		for _, s := range stops {
+			s()
+		}
+	}
+}
+
+func (c Chaos) ShortCircuit() (TaskResult, bool) {
+	for _, m := range c {
+		if res, ok := m.ShortCircuit(); ok {
+			return res, true
+		}
+	}
+	return TaskResult{}, false
+}
+
+// chaosMonkeys holds the faults enabled for this process by run(), nil
+// (inert) unless --chaos/CODEX_WRAPPER_CHAOS was set and acknowledged with
+// --i-know-this-is-dangerous.
+var chaosMonkeys Chaos
+
+// chaosDangerFlag is the acknowledgement flag required alongside --chaos or
+// CODEX_WRAPPER_CHAOS so fault injection can't reach production by way of a
+// stray environment variable alone.
+const chaosDangerFlag = "--i-know-this-is-dangerous"
+
+// resolveChaosSpec returns the --chaos value from args if present,
+// otherwise CODEX_WRAPPER_CHAOS, otherwise "".
+func resolveChaosSpec(args []string) string {
+	if v, ok := flagValue(args, "--chaos"); ok {
+		return v
+	}
+	return getEnv("CODEX_WRAPPER_CHAOS", "")
+}
+
+// delayMonkey sleeps (or returns early on ctx cancellation) before codex is
+// started, simulating a slow scheduler or cold-start latency.
+type delayMonkey struct {
+	noopMonkey
+	d time.Duration
+}
+
+func (m delayMonkey) BeforeStart(ctx context.Context) {
+	select {
+	case <-time.After(m.d):
+	case <-ctx.Done():
+	}
+}
+
+// throttleMonkey rate-limits codex's stdout to bytesPerSec, simulating a
+// slow or congested pipe.
+type throttleMonkey struct {
+	noopMonkey
+	bytesPerSec int64
+}
+
+func (m throttleMonkey) WrapStdout(r io.Reader) io.Reader {
+	return &rateLimitedReader{r: r, bytesPerSec: m.bytesPerSec}
+}
+
+// rateLimitedReader paces Read calls to approximately bytesPerSec.
+type rateLimitedReader struct {
+	r           io.Reader
+	bytesPerSec int64
+}
+
+func (rl *rateLimitedReader) Read(p []byte) (int, error) {
+	if rl.bytesPerSec <= 0 {
+		return rl.r.Read(p)
+	}
+	if int64(len(p)) > rl.bytesPerSec {
+		p = p[:rl.bytesPerSec]
+	}
+	n, err := rl.r.Read(p)
+	if n > 0 {
+		time.Sleep(time.Duration(n) * time.Second / time.Duration(rl.bytesPerSec))
+	}
+	return n, err
+}
+
+// killAfterMonkey sends SIGTERM to the codex process after d, exercising
+// terminateProcess/forceKillDelay's cleanup path mid-stream instead of only
+// at the overall timeout.
+type killAfterMonkey struct {
+	noopMonkey
+	d time.Duration
+}
+
+func (m killAfterMonkey) AfterStart(cmd *exec.Cmd) (stop func()) {
+	timer := time.AfterFunc(m.d, func() {
+		if cmd.Process != nil {
+			_ = cmd.Process.Signal(syscall.SIGTERM)
+		}
+	})
+	return func() { timer.Stop() }
+}
+
+// dropLinesMonkey randomly discards whole lines of codex's stdout before
+// parseJSONStreamWithLog sees them, exercising its warnFn recovery path for
+// truncated or missing JSON events.
+type dropLinesMonkey struct {
+	noopMonkey
+	fraction float64
+}
+
+func (m dropLinesMonkey) WrapStdout(r io.Reader) io.Reader {
+	return &lineDropReader{r: bufio.NewReader(r), fraction: m.fraction}
+}
+
+// lineDropReader re-chunks its input into lines, keeping each with
+// probability 1-fraction and feeding only the kept lines to Read.
+type lineDropReader struct {
+	r        *bufio.Reader
+	fraction float64
+	buf      bytes.Buffer
+}
+
+func (d *lineDropReader) Read(p []byte) (int, error) {
+	for d.buf.Len() == 0 {
+		line, err := d.r.ReadBytes('\n')
+		if len(line) > 0 && rand.Float64() >= d.fraction {
+			d.buf.Write(line)
+		}
+		if err != nil {
+			if d.buf.Len() == 0 {
+				return 0, err
+			}
+			break
+		}
+	}
+	return d.buf.Read(p)
+}
+
+// failRateMonkey short-circuits a fraction of task executions before codex
+// ever runs, returning exitCode as if the process had been killed.
+type failRateMonkey struct {
+	noopMonkey
+	fraction float64
+	exitCode int
+}
+
+func (m failRateMonkey) ShortCircuit() (TaskResult, bool) {
+	if rand.Float64() >= m.fraction {
+		return TaskResult{}, false
+	}
+	return TaskResult{
+		ExitCode: m.exitCode,
+		Error:    fmt.Sprintf("chaos: fail-rate fault triggered (exit %d)", m.exitCode),
+	}, true
+}
+
+// parseChaosSpec parses a comma-separated --chaos spec such as
+// "delay=500ms,stdout-throttle=10KB/s,kill-after=30s,drop-lines=5%,fail-rate=10%"
+// into the Monkeys it names. An empty spec returns a nil (inert) Chaos.
+// Unknown keys or malformed values are a hard error so a typo'd spec fails
+// loudly instead of silently injecting nothing.
+func parseChaosSpec(spec string) (Chaos, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	var monkeys Chaos
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("chaos: invalid fault %q, expected key=value", part)
+		}
+
+		switch key {
+		case "delay":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("chaos: invalid delay %q: %w", value, err)
+			}
+			monkeys = append(monkeys, delayMonkey{d: d})
+
+		case "stdout-throttle":
+			bps, err := parseByteRate(value)
+			if err != nil {
+				return nil, fmt.Errorf("chaos: invalid stdout-throttle %q: %w", value, err)
+			}
+			monkeys = append(monkeys, throttleMonkey{bytesPerSec: bps})
+
+		case "kill-after":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("chaos: invalid kill-after %q: %w", value, err)
+			}
+			monkeys = append(monkeys, killAfterMonkey{d: d})
+
+		case "drop-lines":
+			f, err := parseFraction(value)
+			if err != nil {
+				return nil, fmt.Errorf("chaos: invalid drop-lines %q: %w", value, err)
+			}
+			monkeys = append(monkeys, dropLinesMonkey{fraction: f})
+
+		case "fail-rate":
+			f, err := parseFraction(value)
+			if err != nil {
+				return nil, fmt.Errorf("chaos: invalid fail-rate %q: %w", value, err)
+			}
+			monkeys = append(monkeys, failRateMonkey{fraction: f, exitCode: 137})
+
+		default:
+			return nil, fmt.Errorf("chaos: unknown fault %q", key)
+		}
+	}
+
+	return monkeys, nil
+}
+
+// parseFraction parses a trailing-"%" percentage (e.g. "5%") into [0, 1].
+func parseFraction(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "%")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return v / 100, nil
+}
+
+// parseByteRate parses a rate like "10KB/s", "512B/s" or "2MB/s" into
+// bytes/second.
+func parseByteRate(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "/s")
+
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "KB"):
+		mult = 1024
+		s = strings.TrimSuffix(s, "KB")
+	case strings.HasSuffix(s, "MB"):
+		mult = 1024 * 1024
+		s = strings.TrimSuffix(s, "MB")
+	case strings.HasSuffix(s, "B"):
+		s = strings.TrimSuffix(s, "B")
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("rate must be positive")
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseChaosSpec(t *testing.T) {
+	chaos, err := parseChaosSpec("delay=10ms,stdout-throttle=10KB/s,kill-after=30s,drop-lines=5%,fail-rate=10%")
+	if err != nil {
+		t.Fatalf("parseChaosSpec() error = %v", err)
+	}
+	if len(chaos) != 5 {
+		t.Fatalf("expected 5 monkeys, got %d", len(chaos))
+	}
+
+	if _, ok := chaos[0].(delayMonkey); !ok {
+		t.Errorf("chaos[0] = %T, want delayMonkey", chaos[0])
+	}
+	if m, ok := chaos[1].(throttleMonkey); !ok || m.bytesPerSec != 10*1024 {
+		t.Errorf("chaos[1] = %#v, want throttleMonkey{bytesPerSec: 10240}", chaos[1])
+	}
+	if m, ok := chaos[3].(dropLinesMonkey); !ok || m.fraction != 0.05 {
+		t.Errorf("chaos[3] = %#v, want dropLinesMonkey{fraction: 0.05}", chaos[3])
+	}
+	if m, ok := chaos[4].(failRateMonkey); !ok || m.fraction != 0.1 || m.exitCode != 137 {
+		t.Errorf("chaos[4] = %#v, want failRateMonkey{fraction: 0.1, exitCode: 137}", chaos[4])
+	}
+}
+
+func TestParseChaosSpec_Empty(t *testing.T) {
+	chaos, err := parseChaosSpec("")
+	if err != nil {
+		t.Fatalf("parseChaosSpec(\"\") error = %v", err)
+	}
+	if chaos != nil {
+		t.Errorf("parseChaosSpec(\"\") = %#v, want nil", chaos)
+	}
+}
+
+func TestParseChaosSpec_Invalid(t *testing.T) {
+	cases := []string{
+		"bogus=1",
+		"delay=notaduration",
+		"stdout-throttle=lots",
+		"drop-lines=abc%",
+		"delay",
+	}
+	for _, spec := range cases {
+		if _, err := parseChaosSpec(spec); err == nil {
+			t.Errorf("parseChaosSpec(%q) error = nil, want error", spec)
+		}
+	}
+}
+
+func TestParseFraction(t *testing.T) {
+	cases := map[string]float64{"5%": 0.05, "100%": 1, "0%": 0}
+	for in, want := range cases {
+		got, err := parseFraction(in)
+		if err != nil {
+			t.Fatalf("parseFraction(%q) error = %v", in, err)
+		}
+		if got != want {
+			t.Errorf("parseFraction(%q) = %v, want %v", in, got, want)
+		}
+	}
+	if _, err := parseFraction("abc"); err == nil {
+		t.Error("parseFraction(\"abc\") error = nil, want error")
+	}
+}
+
+func TestParseByteRate(t *testing.T) {
+	cases := map[string]int64{"512B/s": 512, "10KB/s": 10 * 1024, "2MB/s": 2 * 1024 * 1024}
+	for in, want := range cases {
+		got, err := parseByteRate(in)
+		if err != nil {
+			t.Fatalf("parseByteRate(%q) error = %v", in, err)
+		}
+		if got != want {
+			t.Errorf("parseByteRate(%q) = %d, want %d", in, got, want)
+		}
+	}
+	if _, err := parseByteRate("-5B/s"); err == nil {
+		t.Error("parseByteRate(\"-5B/s\") error = nil, want error")
+	}
+}
+
+func TestResolveChaosSpec(t *testing.T) {
+	if got := resolveChaosSpec([]string{"--chaos", "delay=10ms"}); got != "delay=10ms" {
+		t.Errorf("resolveChaosSpec(--chaos) = %q, want %q", got, "delay=10ms")
+	}
+
+	t.Setenv("CODEX_WRAPPER_CHAOS", "fail-rate=50%")
+	if got := resolveChaosSpec(nil); got != "fail-rate=50%" {
+		t.Errorf("resolveChaosSpec(env fallback) = %q, want %q", got, "fail-rate=50%")
+	}
+}
+
+func TestDropLinesMonkey_AllOrNothing(t *testing.T) {
+	input := "one\ntwo\nthree\n"
+
+	keepAll := dropLinesMonkey{fraction: 0}
+	out, err := io.ReadAll(keepAll.WrapStdout(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(out) != input {
+		t.Errorf("fraction=0 dropped lines: got %q, want %q", out, input)
+	}
+
+	dropAll := dropLinesMonkey{fraction: 1}
+	out, err = io.ReadAll(dropAll.WrapStdout(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if len(out) != 0 {
+		t.Errorf("fraction=1 kept lines: got %q, want empty", out)
+	}
+}
+
+func TestFailRateMonkey_ShortCircuit(t *testing.T) {
+	always := failRateMonkey{fraction: 1, exitCode: 137}
+	result, ok := always.ShortCircuit()
+	if !ok {
+		t.Fatal("ShortCircuit() ok = false, want true")
+	}
+	if result.ExitCode != 137 {
+		t.Errorf("result.ExitCode = %d, want 137", result.ExitCode)
+	}
+
+	never := failRateMonkey{fraction: 0, exitCode: 137}
+	if _, ok := never.ShortCircuit(); ok {
+		t.Error("ShortCircuit() ok = true, want false")
+	}
+}
+
+func TestChaos_AggregatesMonkeys(t *testing.T) {
+	chaos := Chaos{failRateMonkey{fraction: 0, exitCode: 137}, failRateMonkey{fraction: 1, exitCode: 137}}
+	result, ok := chaos.ShortCircuit()
+	if !ok || result.ExitCode != 137 {
+		t.Errorf("Chaos.ShortCircuit() = %#v, %v, want exit 137, true", result, ok)
+	}
+
+	var empty Chaos
+	if _, ok := empty.ShortCircuit(); ok {
+		t.Error("empty Chaos.ShortCircuit() ok = true, want false")
+	}
+	if r := empty.WrapStdout(strings.NewReader("x")); r == nil {
+		t.Error("empty Chaos.WrapStdout() = nil, want passthrough reader")
+	}
+	if stop := empty.AfterStart(&exec.Cmd{}); stop != nil {
+		t.Error("empty Chaos.AfterStart() = non-nil, want nil")
+	}
+}
+
+func TestDelayMonkey_CancelledByContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	delayMonkey{d: time.Hour}.BeforeStart(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("BeforeStart() with cancelled ctx took %v, want near-instant", elapsed)
+	}
+}
+
+func TestRateLimitedReader_PassthroughWhenUnset(t *testing.T) {
+	rl := &rateLimitedReader{r: bytes.NewReader([]byte("hello"))}
+	out, err := io.ReadAll(rl)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(out) != "hello" {
+		t.Errorf("got %q, want %q", out, "hello")
+	}
+}
@@ -0,0 +1,690 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Coordinator lets a DAG's tasks be claimed and executed by any number of
+// worker processes, possibly on different hosts, instead of one wrapper
+// running every layer itself (see Scheduler in main.go for the single-host
+// path). A leader-elected submitter publishes the task specs once; workers
+// discover them, claim whichever are unclaimed and already have their
+// dependencies satisfied, run them, and publish the result back.
+type Coordinator interface {
+	// ClaimTask attempts to claim taskID within dagID for workerID via a
+	// compare-and-swap on the task's claim key. It returns true only for the
+	// single caller that won the claim.
+	ClaimTask(dagID, taskID, workerID string) (bool, error)
+
+	// PublishStatus records taskID's status and, once terminal (Succeeded,
+	// Failed, or Skipped), its TaskResult, unblocking any WatchDependency
+	// callers.
+	PublishStatus(dagID, taskID, status string, result *TaskResult) error
+
+	// TaskStatus returns taskID's current published status without blocking,
+	// or "" if nothing has been published yet.
+	TaskStatus(ctx context.Context, dagID, taskID string) (status string, result *TaskResult, err error)
+
+	// WatchDependency blocks until taskID within dagID reaches a terminal
+	// status or ctx is done.
+	WatchDependency(ctx context.Context, dagID, taskID string) (status string, result *TaskResult, err error)
+
+	// PublishTaskSpecs makes dagID's full task list discoverable to workers
+	// that join after submission. Called once by the elected submitter.
+	PublishTaskSpecs(dagID string, tasks []TaskSpec) error
+
+	// ListTaskSpecs returns the task list published for dagID, blocking until
+	// the submitter has published it or ctx is done.
+	ListTaskSpecs(ctx context.Context, dagID string) ([]TaskSpec, error)
+}
+
+func isTerminalStatus(status string) bool {
+	return status == TaskSucceeded || status == TaskFailed || status == TaskSkipped
+}
+
+// coordinatorFromURL selects a Coordinator implementation from a
+// --coordinator address: "local" (or empty) for the in-process
+// implementation, or a consul:// / etcd:// address for the corresponding
+// HTTP-backed one.
+func coordinatorFromURL(raw string) (Coordinator, error) {
+	switch {
+	case raw == "" || raw == "local":
+		return newLocalCoordinator(), nil
+	case strings.HasPrefix(raw, "consul://"):
+		return newConsulCoordinator(raw), nil
+	case strings.HasPrefix(raw, "etcd://"):
+		return newEtcdCoordinator(raw), nil
+	default:
+		return nil, fmt.Errorf("unsupported coordinator address %q (expected local, consul://host:port, or etcd://host:port)", raw)
+	}
+}
+
+// localCoordinator is an in-process Coordinator, primarily useful for tests
+// and for a single-host "--coordinator local" run that exercises the
+// worker-pull execution path without any external KV store.
+type localCoordinator struct {
+	mu      sync.Mutex
+	claims  map[string]string
+	status  map[string]string
+	results map[string]*TaskResult
+	tasks   map[string][]TaskSpec
+	waiters map[string][]chan struct{}
+}
+
+func newLocalCoordinator() *localCoordinator {
+	return &localCoordinator{
+		claims:  make(map[string]string),
+		status:  make(map[string]string),
+		results: make(map[string]*TaskResult),
+		tasks:   make(map[string][]TaskSpec),
+		waiters: make(map[string][]chan struct{}),
+	}
+}
+
+func taskKey(dagID, taskID string) string { return dagID + "/" + taskID }
+
+func (c *localCoordinator) ClaimTask(dagID, taskID, workerID string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := taskKey(dagID, taskID)
+	if _, claimed := c.claims[key]; claimed {
+		return false, nil
+	}
+	c.claims[key] = workerID
+	return true, nil
+}
+
+func (c *localCoordinator) PublishStatus(dagID, taskID, status string, result *TaskResult) error {
+	key := taskKey(dagID, taskID)
+
+	c.mu.Lock()
+	c.status[key] = status
+	if result != nil {
+		c.results[key] = result
+	}
+	waiters := c.waiters[key]
+	delete(c.waiters, key)
+	c.mu.Unlock()
+
+	for _, ch := range waiters {
+		close(ch)
+	}
+	return nil
+}
+
+func (c *localCoordinator) TaskStatus(_ context.Context, dagID, taskID string) (string, *TaskResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := taskKey(dagID, taskID)
+	return c.status[key], c.results[key], nil
+}
+
+func (c *localCoordinator) WatchDependency(ctx context.Context, dagID, taskID string) (string, *TaskResult, error) {
+	key := taskKey(dagID, taskID)
+	for {
+		c.mu.Lock()
+		if status, ok := c.status[key]; ok && isTerminalStatus(status) {
+			result := c.results[key]
+			c.mu.Unlock()
+			return status, result, nil
+		}
+		ch := make(chan struct{})
+		c.waiters[key] = append(c.waiters[key], ch)
+		c.mu.Unlock()
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		}
+	}
+}
+
+func (c *localCoordinator) PublishTaskSpecs(dagID string, tasks []TaskSpec) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tasks[dagID] = tasks
+	return nil
+}
+
+func (c *localCoordinator) ListTaskSpecs(ctx context.Context, dagID string) ([]TaskSpec, error) {
+	for {
+		c.mu.Lock()
+		tasks, ok := c.tasks[dagID]
+		c.mu.Unlock()
+		if ok {
+			return tasks, nil
+		}
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// consulCoordinator speaks Consul's plain HTTP KV API directly (CAS puts,
+// raw reads, and index-based blocking queries) rather than pulling in a
+// client SDK this module-less tree has no way to vendor.
+type consulCoordinator struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newConsulCoordinator(addr string) *consulCoordinator {
+	return &consulCoordinator{
+		baseURL: "http://" + strings.TrimPrefix(addr, "consul://"),
+		client:  &http.Client{Timeout: 35 * time.Second},
+	}
+}
+
+func (c *consulCoordinator) kvPath(parts ...string) string {
+	return c.baseURL + "/v1/kv/codex-wrapper/" + strings.Join(parts, "/")
+}
+
+func (c *consulCoordinator) kvPut(ctx context.Context, url string, value []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+func (c *consulCoordinator) ClaimTask(dagID, taskID, workerID string) (bool, error) {
+	url := c.kvPath("dags", dagID, "tasks", taskID, "claim") + "?cas=0"
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(workerID))
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(body)) == "true", nil
+}
+
+func (c *consulCoordinator) PublishStatus(dagID, taskID, status string, result *TaskResult) error {
+	ctx := context.Background()
+	if err := c.kvPut(ctx, c.kvPath("dags", dagID, "tasks", taskID, "status"), []byte(status)); err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return c.kvPut(ctx, c.kvPath("dags", dagID, "tasks", taskID, "result"), data)
+}
+
+func (c *consulCoordinator) fetchResult(ctx context.Context, dagID, taskID string) (*TaskResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.kvPath("dags", dagID, "tasks", taskID, "result")+"?raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result TaskResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *consulCoordinator) TaskStatus(ctx context.Context, dagID, taskID string) (string, *TaskResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.kvPath("dags", dagID, "tasks", taskID, "status")+"?raw", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	status := strings.TrimSpace(string(data))
+	if !isTerminalStatus(status) {
+		return status, nil, nil
+	}
+	result, err := c.fetchResult(ctx, dagID, taskID)
+	return status, result, err
+}
+
+// WatchDependency uses Consul's index-based blocking queries: each request
+// carries the index last observed, and Consul holds the connection open
+// until the key changes (or the wait duration elapses), so this is a real
+// long-poll rather than a tight client-side loop.
+func (c *consulCoordinator) WatchDependency(ctx context.Context, dagID, taskID string) (string, *TaskResult, error) {
+	statusURL := c.kvPath("dags", dagID, "tasks", taskID, "status") + "?raw"
+	index := "0"
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, statusURL+"&index="+index+"&wait=30s", nil)
+		if err != nil {
+			return "", nil, err
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", nil, ctx.Err()
+			}
+			return "", nil, err
+		}
+		newIndex := resp.Header.Get("X-Consul-Index")
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		status := strings.TrimSpace(string(body))
+		if resp.StatusCode == http.StatusOK && isTerminalStatus(status) {
+			result, err := c.fetchResult(ctx, dagID, taskID)
+			return status, result, err
+		}
+		if newIndex != "" {
+			index = newIndex
+		}
+		if ctx.Err() != nil {
+			return "", nil, ctx.Err()
+		}
+	}
+}
+
+func (c *consulCoordinator) PublishTaskSpecs(dagID string, tasks []TaskSpec) error {
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	}
+	return c.kvPut(context.Background(), c.kvPath("dags", dagID, "tasks.json"), data)
+}
+
+func (c *consulCoordinator) ListTaskSpecs(ctx context.Context, dagID string) ([]TaskSpec, error) {
+	url := c.kvPath("dags", dagID, "tasks.json") + "?raw"
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.client.Do(req)
+		if err == nil && resp.StatusCode == http.StatusOK {
+			data, readErr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if readErr != nil {
+				return nil, readErr
+			}
+			var tasks []TaskSpec
+			if err := json.Unmarshal(data, &tasks); err != nil {
+				return nil, err
+			}
+			return tasks, nil
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(500 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// etcdCoordinator speaks etcd's v3 JSON gRPC-gateway API (plain HTTP POSTs
+// with base64-encoded keys/values), the same dependency-free approach as
+// consulCoordinator, since vendoring the etcd client (which requires gRPC)
+// isn't possible in this module-less tree.
+type etcdCoordinator struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newEtcdCoordinator(addr string) *etcdCoordinator {
+	return &etcdCoordinator{
+		baseURL: "http://" + strings.TrimPrefix(addr, "etcd://"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func etcdDagKey(dagID, field string) string {
+	return fmt.Sprintf("codex-wrapper/dags/%s/%s", dagID, field)
+}
+
+func etcdTaskKey(dagID, taskID, field string) string {
+	return fmt.Sprintf("codex-wrapper/dags/%s/tasks/%s/%s", dagID, taskID, field)
+}
+
+func (c *etcdCoordinator) put(ctx context.Context, key string, value []byte) error {
+	data, err := json.Marshal(map[string]string{
+		"key":   base64.StdEncoding.EncodeToString([]byte(key)),
+		"value": base64.StdEncoding.EncodeToString(value),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v3/kv/put", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+func (c *etcdCoordinator) get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := json.Marshal(map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(key))})
+	if err != nil {
+		return nil, false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v3/kv/range", bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Kvs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, false, err
+	}
+	if len(result.Kvs) == 0 {
+		return nil, false, nil
+	}
+	value, err := base64.StdEncoding.DecodeString(result.Kvs[0].Value)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// ClaimTask implements the CAS claim as an etcd transaction: put the claim
+// key only if its create_revision is still 0, i.e. it doesn't exist yet.
+func (c *etcdCoordinator) ClaimTask(dagID, taskID, workerID string) (bool, error) {
+	key := etcdTaskKey(dagID, taskID, "claim")
+	encodedKey := base64.StdEncoding.EncodeToString([]byte(key))
+	txn := map[string]any{
+		"compare": []map[string]any{
+			{"key": encodedKey, "target": "CREATE", "create_revision": 0},
+		},
+		"success": []map[string]any{
+			{"request_put": map[string]any{"key": encodedKey, "value": base64.StdEncoding.EncodeToString([]byte(workerID))}},
+		},
+	}
+	data, err := json.Marshal(txn)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.client.Post(c.baseURL+"/v3/kv/txn", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Succeeded bool `json:"succeeded"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	return result.Succeeded, nil
+}
+
+func (c *etcdCoordinator) PublishStatus(dagID, taskID, status string, result *TaskResult) error {
+	ctx := context.Background()
+	if err := c.put(ctx, etcdTaskKey(dagID, taskID, "status"), []byte(status)); err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return c.put(ctx, etcdTaskKey(dagID, taskID, "result"), data)
+}
+
+func (c *etcdCoordinator) TaskStatus(ctx context.Context, dagID, taskID string) (string, *TaskResult, error) {
+	value, ok, err := c.get(ctx, etcdTaskKey(dagID, taskID, "status"))
+	if err != nil || !ok {
+		return "", nil, err
+	}
+	status := string(value)
+	if !isTerminalStatus(status) {
+		return status, nil, nil
+	}
+	resultBytes, hasResult, err := c.get(ctx, etcdTaskKey(dagID, taskID, "result"))
+	if err != nil || !hasResult {
+		return status, nil, err
+	}
+	var result TaskResult
+	if err := json.Unmarshal(resultBytes, &result); err != nil {
+		return "", nil, err
+	}
+	return status, &result, nil
+}
+
+// WatchDependency polls the status key rather than opening a true etcd watch
+// stream: the v3 Watch RPC needs a long-lived bidirectional connection that
+// the gRPC-gateway's plain request/response JSON endpoints don't expose.
+func (c *etcdCoordinator) WatchDependency(ctx context.Context, dagID, taskID string) (string, *TaskResult, error) {
+	for {
+		status, result, err := c.TaskStatus(ctx, dagID, taskID)
+		if err != nil {
+			return "", nil, err
+		}
+		if isTerminalStatus(status) {
+			return status, result, nil
+		}
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		}
+	}
+}
+
+func (c *etcdCoordinator) PublishTaskSpecs(dagID string, tasks []TaskSpec) error {
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	}
+	return c.put(context.Background(), etcdDagKey(dagID, "tasks.json"), data)
+}
+
+func (c *etcdCoordinator) ListTaskSpecs(ctx context.Context, dagID string) ([]TaskSpec, error) {
+	key := etcdDagKey(dagID, "tasks.json")
+	for {
+		data, ok, err := c.get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			var tasks []TaskSpec
+			if err := json.Unmarshal(data, &tasks); err != nil {
+				return nil, err
+			}
+			return tasks, nil
+		}
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// dependenciesReady reports whether every one of deps has succeeded, without
+// blocking — a worker with no ready task should go round and check the rest
+// of the DAG rather than stall on one dependency.
+func dependenciesReady(ctx context.Context, coord Coordinator, dagID string, deps []string) bool {
+	for _, dep := range deps {
+		status, _, err := coord.TaskStatus(ctx, dagID, dep)
+		if err != nil || status != TaskSucceeded {
+			return false
+		}
+	}
+	return true
+}
+
+// runCoordinatedSubmitter publishes cfg's task specs to coord under dagID so
+// any number of `--parallel --worker` processes can pick them up, then waits
+// for every task's final result and renders the same summary the single-host
+// --parallel path does.
+func runCoordinatedSubmitter(ctx context.Context, coord Coordinator, dagID string, cfg *ParallelConfig) int {
+	if _, err := topologicalSort(cfg.Tasks); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+
+	if err := coord.PublishTaskSpecs(dagID, cfg.Tasks); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to publish task specs: %v\n", err)
+		return 1
+	}
+
+	results := make([]TaskResult, 0, len(cfg.Tasks))
+	for _, task := range cfg.Tasks {
+		status, result, err := coord.WatchDependency(ctx, dagID, task.ID)
+		if err != nil {
+			results = append(results, cancelledResult(task))
+			continue
+		}
+		if result != nil {
+			results = append(results, *result)
+			continue
+		}
+		results = append(results, TaskResult{TaskID: task.ID, ExitCode: 1, Error: fmt.Sprintf("no result published for status %s", status)})
+	}
+
+	fmt.Println(generateFinalOutput(results))
+
+	exitCode := 0
+	for _, res := range results {
+		if res.ExitCode != 0 {
+			exitCode = res.ExitCode
+		}
+	}
+	return exitCode
+}
+
+// runCoordinatedWorker joins dagID, repeatedly claiming and running whichever
+// unclaimed tasks already have their dependencies satisfied until none
+// remain, then exits. Multiple workers can run this concurrently against the
+// same dagID; each task still only runs once, decided by ClaimTask's CAS.
+func runCoordinatedWorker(ctx context.Context, coord Coordinator, dagID, workerID string, timeoutSec int) int {
+	tasks, err := coord.ListTaskSpecs(ctx, dagID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to discover DAG %s: %v\n", dagID, err)
+		return 1
+	}
+
+	remaining := make(map[string]TaskSpec, len(tasks))
+	for _, t := range tasks {
+		remaining[t.ID] = t
+	}
+
+	for len(remaining) > 0 {
+		if ctx.Err() != nil {
+			return 130
+		}
+
+		progressed := false
+		for id, task := range remaining {
+			if !dependenciesReady(ctx, coord, dagID, task.Dependencies) {
+				continue
+			}
+
+			claimed, err := coord.ClaimTask(dagID, task.ID, workerID)
+			if err != nil {
+				logWarn(fmt.Sprintf("coordinator claim failed for %s: %v", task.ID, err))
+				continue
+			}
+			delete(remaining, id)
+			progressed = true
+			if !claimed {
+				continue
+			}
+
+			logInfo(fmt.Sprintf("worker %s claimed task %s", workerID, task.ID))
+			result := runTaskWithRetry(ctx, task, timeoutSec)
+			status := TaskSucceeded
+			if result.ExitCode != 0 || result.Error != "" {
+				status = TaskFailed
+			}
+			if err := coord.PublishStatus(dagID, task.ID, status, &result); err != nil {
+				logWarn(fmt.Sprintf("failed to publish status for %s: %v", task.ID, err))
+			}
+		}
+
+		if !progressed {
+			select {
+			case <-time.After(200 * time.Millisecond):
+			case <-ctx.Done():
+				return 130
+			}
+		}
+	}
+
+	return 0
+}
+
+func flagValue(args []string, name string) (string, bool) {
+	for i, a := range args {
+		if a == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+func hasFlag(args []string, name string) bool {
+	for _, a := range args {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCoordinatorFromURL(t *testing.T) {
+	if _, err := coordinatorFromURL(""); err != nil {
+		t.Errorf("expected local coordinator for empty address, got error: %v", err)
+	}
+	if _, err := coordinatorFromURL("local"); err != nil {
+		t.Errorf("expected local coordinator for %q, got error: %v", "local", err)
+	}
+	if _, err := coordinatorFromURL("consul://127.0.0.1:8500"); err != nil {
+		t.Errorf("unexpected error for consul address: %v", err)
+	}
+	if _, err := coordinatorFromURL("etcd://127.0.0.1:2379"); err != nil {
+		t.Errorf("unexpected error for etcd address: %v", err)
+	}
+	if _, err := coordinatorFromURL("zookeeper://127.0.0.1:2181"); err == nil {
+		t.Error("expected an error for an unsupported scheme")
+	}
+}
+
+func TestLocalCoordinator_ClaimTaskIsSingleWinner(t *testing.T) {
+	coord := newLocalCoordinator()
+
+	first, err := coord.ClaimTask("dag-1", "build", "worker-a")
+	if err != nil || !first {
+		t.Fatalf("expected first claim to win, got claimed=%v err=%v", first, err)
+	}
+
+	second, err := coord.ClaimTask("dag-1", "build", "worker-b")
+	if err != nil || second {
+		t.Fatalf("expected second claim to lose, got claimed=%v err=%v", second, err)
+	}
+}
+
+func TestLocalCoordinator_WatchDependencyUnblocksOnPublish(t *testing.T) {
+	coord := newLocalCoordinator()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	var status string
+	var result *TaskResult
+	go func() {
+		status, result, _ = coord.WatchDependency(ctx, "dag-1", "build")
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	want := TaskResult{TaskID: "build", ExitCode: 0}
+	if err := coord.PublishStatus("dag-1", "build", TaskSucceeded, &want); err != nil {
+		t.Fatalf("PublishStatus() error = %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("WatchDependency did not unblock after PublishStatus")
+	}
+
+	if status != TaskSucceeded {
+		t.Errorf("status = %q, want %q", status, TaskSucceeded)
+	}
+	if result == nil || result.TaskID != "build" {
+		t.Errorf("result = %+v, want TaskID=build", result)
+	}
+}
+
+func TestLocalCoordinator_TaskStatusNeverBlocks(t *testing.T) {
+	coord := newLocalCoordinator()
+	status, result, err := coord.TaskStatus(context.Background(), "dag-1", "never-published")
+	if err != nil {
+		t.Fatalf("TaskStatus() error = %v", err)
+	}
+	if status != "" || result != nil {
+		t.Errorf("expected empty status and nil result, got status=%q result=%+v", status, result)
+	}
+}
+
+func TestDependenciesReady(t *testing.T) {
+	coord := newLocalCoordinator()
+	ctx := context.Background()
+
+	if dependenciesReady(ctx, coord, "dag-1", []string{"build"}) {
+		t.Error("expected dependenciesReady to be false before build succeeds")
+	}
+
+	if err := coord.PublishStatus("dag-1", "build", TaskSucceeded, &TaskResult{TaskID: "build"}); err != nil {
+		t.Fatalf("PublishStatus() error = %v", err)
+	}
+	if !dependenciesReady(ctx, coord, "dag-1", []string{"build"}) {
+		t.Error("expected dependenciesReady to be true after build succeeds")
+	}
+
+	if err := coord.PublishStatus("dag-1", "lint", TaskFailed, &TaskResult{TaskID: "lint", ExitCode: 1}); err != nil {
+		t.Fatalf("PublishStatus() error = %v", err)
+	}
+	if dependenciesReady(ctx, coord, "dag-1", []string{"build", "lint"}) {
+		t.Error("expected dependenciesReady to be false when a dependency failed")
+	}
+}
+
+func TestRunCoordinatedWorker_ClaimsAndRunsPublishedTasks(t *testing.T) {
+	defer resetTestHooks()
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{} }
+	codexCommand = "cat"
+
+	coord := newLocalCoordinator()
+	tasks := []TaskSpec{
+		{ID: "build", Task: `{"type":"item.completed","item":{"type":"agent_message","text":"ok"}}`, UseStdin: true},
+		{ID: "deploy", Dependencies: []string{"build"}, Task: `{"type":"item.completed","item":{"type":"agent_message","text":"ok"}}`, UseStdin: true},
+	}
+	if err := coord.PublishTaskSpecs("dag-1", tasks); err != nil {
+		t.Fatalf("PublishTaskSpecs() error = %v", err)
+	}
+
+	exitCode := runCoordinatedWorker(context.Background(), coord, "dag-1", "worker-a", 5)
+	if exitCode != 0 {
+		t.Fatalf("runCoordinatedWorker() exit code = %d, want 0", exitCode)
+	}
+
+	for _, id := range []string{"build", "deploy"} {
+		status, result, err := coord.TaskStatus(context.Background(), "dag-1", id)
+		if err != nil {
+			t.Fatalf("TaskStatus(%q) error = %v", id, err)
+		}
+		if status != TaskSucceeded {
+			t.Errorf("task %q status = %q, want %q", id, status, TaskSucceeded)
+		}
+		if result == nil || result.ExitCode != 0 {
+			t.Errorf("task %q result = %+v, want ExitCode 0", id, result)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/pprof"
+	"runtime/trace"
+	"syscall"
+	"time"
+)
+
+// debugProfileDuration is how long captureDebugBundle's CPU and execution
+// trace profiles run for; a var (not a const) so tests can shorten it, same
+// rationale as forceKillDelay.
+var debugProfileDuration = 5 * time.Second
+
+// debugNamedProfiles are the runtime/pprof lookups captured verbatim (no
+// sampling window) alongside the timed cpu/trace profiles.
+var debugNamedProfiles = []string{"goroutine", "heap", "allocs", "threadcreate", "block", "mutex"}
+
+// captureDebugBundle writes a one-shot diagnostic bundle - the profiles in
+// debugNamedProfiles plus a debugProfileDuration CPU profile and execution
+// trace - into a new codex-wrapper-<PID>-debug-<timestamp>/ directory next
+// to logPath, and returns the directory path.
+func captureDebugBundle(logPath string) (string, error) {
+	dir := filepath.Join(filepath.Dir(logPath), fmt.Sprintf("codex-wrapper-%d-debug-%s", os.Getpid(), time.Now().UTC().Format("20060102T150405.000000000Z")))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	for _, name := range debugNamedProfiles {
+		if err := writeNamedProfile(dir, name); err != nil {
+			return dir, err
+		}
+	}
+	if err := captureCPUProfile(dir); err != nil {
+		return dir, err
+	}
+	if err := captureExecutionTrace(dir); err != nil {
+		return dir, err
+	}
+
+	return dir, nil
+}
+
+func writeNamedProfile(dir, name string) error {
+	p := pprof.Lookup(name)
+	if p == nil {
+		return fmt.Errorf("unknown pprof profile %q", name)
+	}
+
+	f, err := os.Create(filepath.Join(dir, name+".pprof"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return p.WriteTo(f, 0)
+}
+
+func captureCPUProfile(dir string) error {
+	f, err := os.Create(filepath.Join(dir, "cpu.pprof"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := pprof.StartCPUProfile(f); err != nil {
+		return err
+	}
+	time.Sleep(debugProfileDuration)
+	pprof.StopCPUProfile()
+	return nil
+}
+
+func captureExecutionTrace(dir string) error {
+	f, err := os.Create(filepath.Join(dir, "trace.out"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := trace.Start(f); err != nil {
+		return err
+	}
+	time.Sleep(debugProfileDuration)
+	trace.Stop()
+	return nil
+}
+
+// debugDumpFlag is the hidden CLI trigger for a one-shot debug bundle,
+// checked directly against os.Args (like chaosDangerFlag) rather than
+// registered on the wrapper's flag.FlagSet, so it never shows up in --help.
+const debugDumpFlag = "--debug-dump"
+
+// installDebugDumpHandler watches for SIGUSR1 and writes a fresh debug
+// bundle next to logger's log file on each delivery, announcing the bundle
+// path through logger.Info. The returned stop func releases the signal
+// handler and must be deferred by the caller.
+func installDebugDumpHandler(logger *Logger) (stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				dumpDebugBundle(logger)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
+
+// dumpDebugBundle captures a debug bundle next to logger's log file and
+// announces (or logs the failure of) it through logger.Info/Warn.
+func dumpDebugBundle(logger *Logger) {
+	if logger == nil {
+		return
+	}
+	dir, err := captureDebugBundle(logger.Path())
+	if err != nil {
+		logger.Warn(fmt.Sprintf("debug dump failed: %v", err))
+		return
+	}
+	logger.Info(fmt.Sprintf("debug dump written to %s", dir))
+}
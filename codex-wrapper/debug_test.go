@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestInstallDebugDumpHandler_CapturesBundleOnSignal(t *testing.T) {
+	old := debugProfileDuration
+	debugProfileDuration = 10 * time.Millisecond
+	defer func() { debugProfileDuration = old }()
+
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	stop := installDebugDumpHandler(logger)
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+
+	// Poll for trace.out specifically, not just the directory: captureDebugBundle
+	// creates the directory first and fills it in sequentially, so the
+	// directory can exist well before every profile inside it is written.
+	// trace.out is written last, so its presence means the bundle is complete.
+	var dir string
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		entries, err := os.ReadDir(tempDir)
+		if err != nil {
+			t.Fatalf("ReadDir: %v", err)
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				if _, err := os.Stat(filepath.Join(tempDir, e.Name(), "trace.out")); err == nil {
+					dir = filepath.Join(tempDir, e.Name())
+				}
+			}
+		}
+		if dir != "" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if dir == "" {
+		t.Fatalf("expected a completed debug bundle directory under %s", tempDir)
+	}
+
+	wantFiles := append(append([]string(nil), debugNamedProfiles...), "cpu", "trace")
+	for _, name := range wantFiles {
+		ext := ".pprof"
+		if name == "trace" {
+			ext = ".out"
+		}
+		path := filepath.Join(dir, name+ext)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", path, err)
+		}
+		if len(data) == 0 {
+			t.Fatalf("expected %s to be non-empty", path)
+		}
+	}
+
+	// The named pprof profiles (and the cpu profile) are gzip-compressed
+	// protobuf, per runtime/pprof.Profile.WriteTo's documented format -
+	// checking the gzip magic header confirms they're in the expected,
+	// parseable shape without needing a full protobuf decoder.
+	for _, name := range append(append([]string(nil), debugNamedProfiles...), "cpu") {
+		data, err := os.ReadFile(filepath.Join(dir, name+".pprof"))
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", name, err)
+		}
+		if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+			t.Fatalf("expected %s.pprof to be gzip-compressed pprof data", name)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNeedsStore is returned by NewExporter when constructed without a Store
+// to read from.
+var ErrNeedsStore = errors.New("exporter: a Store is required")
+
+// Store accumulates task lifecycle observations for the Exporter, the same
+// way metricsRegistry does for the /metrics endpoint - but scoped to the
+// --parallel execution path and kept separate so a push target outage never
+// affects the scrape-based metrics server.
+type Store struct {
+	StateCounts   *Counter   // label: state (queued|running|succeeded|failed|skipped)
+	TaskDuration  *Histogram // label: id_prefix
+	Retries       *Counter   // label: id_prefix
+	LayerDuration *Histogram // label: layer index, as a string
+}
+
+// NewStore returns an empty Store ready to record task transitions.
+func NewStore() *Store {
+	return &Store{
+		StateCounts:   newCounter(),
+		TaskDuration:  newHistogram(),
+		Retries:       newCounter(),
+		LayerDuration: newHistogram(),
+	}
+}
+
+// RecordTransition counts a task entering state (one of the Task* constants).
+func (s *Store) RecordTransition(taskID, state string) {
+	s.StateCounts.Inc(state)
+}
+
+// RecordResult folds a finished task's outcome into the duration histogram
+// and retry counter. The mapping is identical for "new" and "resume" mode
+// tasks - Mode only affects how runCodexTaskExec invokes codex, not how the
+// outcome is reported here.
+func (s *Store) RecordResult(taskID string, result TaskResult, duration time.Duration) {
+	label := idPrefixLabel(taskID)
+	s.TaskDuration.Observe(label, duration.Seconds())
+	if retries := len(result.Attempts) - 1; retries > 0 {
+		s.Retries.Add(label, int64(retries))
+	}
+}
+
+// RecordLayer records how long a DAG layer took to fully drain.
+func (s *Store) RecordLayer(layer int, duration time.Duration) {
+	s.LayerDuration.Observe(strconv.Itoa(layer), duration.Seconds())
+}
+
+// exportStore is the process-wide Store that runCodexTaskWithContext and
+// Scheduler.Run write into, mirroring metricsRegistry's role for the scrape
+// endpoint.
+var exportStore = NewStore()
+
+// exporterOpts is the resolved configuration a Target renders against.
+type exporterOpts struct {
+	omitTaskLabel bool
+	emitTimestamp bool
+}
+
+// Target is a destination the Exporter pushes a Store snapshot to on every
+// interval tick.
+type Target interface {
+	Push(ctx context.Context, store *Store, opts exporterOpts) error
+}
+
+// PrometheusPushGatewayTarget POSTs the store in Prometheus text exposition
+// format to a pushgateway-compatible URL, with exporter_-prefixed metric
+// names so it never collides with the registry served at /metrics.
+type PrometheusPushGatewayTarget struct {
+	URL string
+}
+
+func (t *PrometheusPushGatewayTarget) Push(ctx context.Context, store *Store, opts exporterOpts) error {
+	var buf bytes.Buffer
+
+	stateCounts := store.StateCounts.snapshot()
+	durations := store.TaskDuration.snapshot()
+	retries := store.Retries.snapshot()
+	layers := store.LayerDuration.snapshot()
+	if opts.omitTaskLabel {
+		stateCounts = sumCounts(stateCounts)
+		durations = mergeHistograms(durations)
+		retries = sumCounts(retries)
+	}
+
+	writeCounter(&buf, "exporter_task_state_total", "state", stateCounts)
+	writeHistogram(&buf, "exporter_task_duration_seconds", "id_prefix", durations)
+	writeCounter(&buf, "exporter_task_retries_total", "id_prefix", retries)
+	writeHistogram(&buf, "exporter_layer_duration_seconds", "layer", layers)
+	if opts.emitTimestamp {
+		fmt.Fprintf(&buf, "# pushed_at %d\n", time.Now().Unix())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// storeSnapshot is the JSON body JSONHTTPTarget posts.
+type storeSnapshot struct {
+	StateCounts   map[string]int64         `json:"state_counts"`
+	TaskDuration  map[string]histogramData `json:"task_duration,omitempty"`
+	Retries       map[string]int64         `json:"retries,omitempty"`
+	LayerDuration map[string]histogramData `json:"layer_duration,omitempty"`
+	PushedAt      int64                    `json:"pushed_at,omitempty"`
+}
+
+// JSONHTTPTarget POSTs the store as a single JSON document, for sinks that
+// don't speak Prometheus exposition format.
+type JSONHTTPTarget struct {
+	URL string
+}
+
+func (t *JSONHTTPTarget) Push(ctx context.Context, store *Store, opts exporterOpts) error {
+	stateCounts := store.StateCounts.snapshot()
+	durations := store.TaskDuration.snapshot()
+	retries := store.Retries.snapshot()
+	if opts.omitTaskLabel {
+		stateCounts = sumCounts(stateCounts)
+		durations = mergeHistograms(durations)
+		retries = sumCounts(retries)
+	}
+
+	snap := storeSnapshot{
+		StateCounts:   stateCounts,
+		TaskDuration:  durations,
+		Retries:       retries,
+		LayerDuration: store.LayerDuration.snapshot(),
+	}
+	if opts.emitTimestamp {
+		snap.PushedAt = time.Now().Unix()
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(snap); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// sumCounts collapses every label into a single "all" bucket, for
+// OmitTaskLabel(), where per-task cardinality isn't wanted on the wire.
+func sumCounts(in map[string]int64) map[string]int64 {
+	var total int64
+	for _, v := range in {
+		total += v
+	}
+	return map[string]int64{"all": total}
+}
+
+// mergeHistograms collapses every label's histogramData into a single "all"
+// bucket, summing bucket counts, sum, and count.
+func mergeHistograms(in map[string]histogramData) map[string]histogramData {
+	merged := histogramData{counts: make([]int64, len(histogramBuckets))}
+	for _, d := range in {
+		for i := range merged.counts {
+			if i < len(d.counts) {
+				merged.counts[i] += d.counts[i]
+			}
+		}
+		merged.sum += d.sum
+		merged.count += d.count
+	}
+	return map[string]histogramData{"all": merged}
+}
+
+// Exporter periodically pushes a Store's contents to one or more configured
+// Targets, modeled on the mtail-style push exporter: construct with options,
+// Start it alongside the scheduler, Stop it during shutdown for a guaranteed
+// final flush.
+type Exporter struct {
+	store    *Store
+	interval time.Duration
+	targets  []Target
+	disabled bool
+	opts     exporterOpts
+
+	cancel       context.CancelFunc
+	shutdownDone chan struct{}
+}
+
+// ExporterOption configures an Exporter constructed via NewExporter.
+type ExporterOption func(*Exporter)
+
+// PushInterval sets how often the Exporter pushes to its targets. Default 15s.
+func PushInterval(d time.Duration) ExporterOption {
+	return func(e *Exporter) { e.interval = d }
+}
+
+// PushTarget adds a destination the Exporter pushes to on every tick.
+func PushTarget(t Target) ExporterOption {
+	return func(e *Exporter) { e.targets = append(e.targets, t) }
+}
+
+// DisableExport turns Start into a no-op, for environments (tests, CI) that
+// don't want background HTTP pushes regardless of configured targets.
+func DisableExport() ExporterOption {
+	return func(e *Exporter) { e.disabled = true }
+}
+
+// OmitTaskLabel aggregates every metric across all tasks before pushing,
+// for deployments where per-task cardinality isn't wanted on the wire.
+func OmitTaskLabel() ExporterOption {
+	return func(e *Exporter) { e.opts.omitTaskLabel = true }
+}
+
+// EmitTimestamp adds a push timestamp to each pushed payload.
+func EmitTimestamp() ExporterOption {
+	return func(e *Exporter) { e.opts.emitTimestamp = true }
+}
+
+// NewExporter builds an Exporter reading from store. It returns ErrNeedsStore
+// if store is nil.
+func NewExporter(store *Store, opts ...ExporterOption) (*Exporter, error) {
+	if store == nil {
+		return nil, ErrNeedsStore
+	}
+	e := &Exporter{store: store, interval: 15 * time.Second}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, nil
+}
+
+// Start begins pushing on every interval tick until ctx is cancelled or Stop
+// is called. The returned channel closes once the background goroutine has
+// fully stopped, after a final flush. Start is a no-op if DisableExport was
+// set or no targets were configured.
+func (e *Exporter) Start(ctx context.Context) <-chan struct{} {
+	shutdownDone := make(chan struct{})
+	if e.disabled || len(e.targets) == 0 {
+		close(shutdownDone)
+		return shutdownDone
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	e.cancel = cancel
+	e.shutdownDone = shutdownDone
+
+	go func() {
+		defer close(shutdownDone)
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-runCtx.Done():
+				e.pushAll(context.Background())
+				return
+			case <-ticker.C:
+				e.pushAll(runCtx)
+			}
+		}
+	}()
+	return shutdownDone
+}
+
+// Stop cancels the Exporter's push loop and blocks until its final flush has
+// completed.
+func (e *Exporter) Stop() {
+	if e.cancel == nil {
+		return
+	}
+	e.cancel()
+	<-e.shutdownDone
+}
+
+func (e *Exporter) pushAll(ctx context.Context) {
+	for _, target := range e.targets {
+		if err := target.Push(ctx, e.store, e.opts); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: exporter push failed: %v\n", err)
+		}
+	}
+}
+
+// resolveExportTargets returns --export-push-url (args) or
+// CODEX_EXPORT_PUSH_URL (env), a comma-separated list of one or more push
+// target URLs, as Targets built according to --export-format/
+// CODEX_EXPORT_FORMAT ("prom", the default, or "json"). Returns nil (no
+// targets configured) when neither is set - the common case, matching
+// maybeStartMetricsPusher's url=="" no-op for the scrape-based registry.
+func resolveExportTargets(args []string) []Target {
+	raw, ok := flagValue(args, "--export-push-url")
+	if !ok {
+		raw = getEnv("CODEX_EXPORT_PUSH_URL", "")
+	}
+	if raw == "" {
+		return nil
+	}
+
+	format, ok := flagValue(args, "--export-format")
+	if !ok {
+		format = getEnv("CODEX_EXPORT_FORMAT", "prom")
+	}
+
+	var targets []Target
+	for _, url := range strings.Split(raw, ",") {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		switch format {
+		case "json":
+			targets = append(targets, &JSONHTTPTarget{URL: url})
+		default:
+			targets = append(targets, &PrometheusPushGatewayTarget{URL: url})
+		}
+	}
+	return targets
+}
+
+// resolveExportInterval returns --export-push-interval/CODEX_EXPORT_PUSH_INTERVAL
+// (a Go duration string, e.g. "15s") or a 15s default.
+func resolveExportInterval(args []string) time.Duration {
+	raw, ok := flagValue(args, "--export-push-interval")
+	if !ok {
+		raw = getEnv("CODEX_EXPORT_PUSH_INTERVAL", "")
+	}
+	if raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+			return d
+		}
+	}
+	return 15 * time.Second
+}
+
+// maybeStartExporter starts exportStore's push-mode Exporter if
+// --export-push-url/CODEX_EXPORT_PUSH_URL configures at least one target,
+// otherwise it's a no-op (nil Exporter, pre-closed done channel) - the
+// default, since Store's data is otherwise only readable by tests that
+// construct their own Exporter directly.
+func maybeStartExporter(ctx context.Context, args []string) (*Exporter, <-chan struct{}) {
+	targets := resolveExportTargets(args)
+	opts := []ExporterOption{PushInterval(resolveExportInterval(args))}
+	for _, t := range targets {
+		opts = append(opts, PushTarget(t))
+	}
+	if hasFlag(args, "--export-omit-task-label") || getEnv("CODEX_EXPORT_OMIT_TASK_LABEL", "") != "" {
+		opts = append(opts, OmitTaskLabel())
+	}
+	if hasFlag(args, "--export-timestamp") || getEnv("CODEX_EXPORT_TIMESTAMP", "") != "" {
+		opts = append(opts, EmitTimestamp())
+	}
+
+	exporter, err := NewExporter(exportStore, opts...)
+	if err != nil {
+		done := make(chan struct{})
+		close(done)
+		return nil, done
+	}
+	return exporter, exporter.Start(ctx)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"context"
+)
+
+// countingTarget records how many times Push was invoked, for push-on-
+// interval and shutdown-flush assertions that don't need a real HTTP round
+// trip.
+type countingTarget struct {
+	pushes atomic.Int64
+}
+
+func (t *countingTarget) Push(ctx context.Context, store *Store, opts exporterOpts) error {
+	t.pushes.Add(1)
+	return nil
+}
+
+func TestExporter_PushesOnInterval(t *testing.T) {
+	store := NewStore()
+	target := &countingTarget{}
+	exp, err := NewExporter(store, PushInterval(10*time.Millisecond), PushTarget(target))
+	if err != nil {
+		t.Fatalf("NewExporter() error = %v", err)
+	}
+
+	done := exp.Start(context.Background())
+	defer exp.Stop()
+
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for target.pushes.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := target.pushes.Load(); got < 3 {
+		t.Fatalf("expected at least 3 pushes within %s, got %d", 500*time.Millisecond, got)
+	}
+
+	select {
+	case <-done:
+		t.Fatalf("shutdownDone closed before Stop() was called")
+	default:
+	}
+}
+
+func TestExporter_StopFlushesBeforeReturning(t *testing.T) {
+	store := NewStore()
+	target := &countingTarget{}
+	exp, err := NewExporter(store, PushInterval(time.Hour), PushTarget(target))
+	if err != nil {
+		t.Fatalf("NewExporter() error = %v", err)
+	}
+
+	exp.Start(context.Background())
+	before := target.pushes.Load()
+	exp.Stop()
+	if after := target.pushes.Load(); after <= before {
+		t.Fatalf("expected Stop() to trigger a final flush, pushes before=%d after=%d", before, after)
+	}
+}
+
+func TestExporter_DisableExportSkipsPushes(t *testing.T) {
+	store := NewStore()
+	target := &countingTarget{}
+	exp, err := NewExporter(store, PushInterval(10*time.Millisecond), PushTarget(target), DisableExport())
+	if err != nil {
+		t.Fatalf("NewExporter() error = %v", err)
+	}
+
+	done := exp.Start(context.Background())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected Start() to return an already-closed channel when disabled")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := target.pushes.Load(); got != 0 {
+		t.Fatalf("expected no pushes with DisableExport(), got %d", got)
+	}
+}
+
+func TestNewExporter_RequiresStore(t *testing.T) {
+	if _, err := NewExporter(nil); err != ErrNeedsStore {
+		t.Fatalf("NewExporter(nil) error = %v, want ErrNeedsStore", err)
+	}
+}
+
+func TestResolveExportTargets_FlagOverridesEnvAndSplitsList(t *testing.T) {
+	t.Setenv("CODEX_EXPORT_PUSH_URL", "http://env-only/push")
+	targets := resolveExportTargets(nil)
+	if len(targets) != 1 {
+		t.Fatalf("expected 1 target from env fallback, got %d", len(targets))
+	}
+	if _, ok := targets[0].(*PrometheusPushGatewayTarget); !ok {
+		t.Errorf("default format = %T, want *PrometheusPushGatewayTarget", targets[0])
+	}
+
+	targets = resolveExportTargets([]string{"--export-push-url", "http://a/push,http://b/push", "--export-format", "json"})
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets from comma-separated flag, got %d", len(targets))
+	}
+	if _, ok := targets[0].(*JSONHTTPTarget); !ok {
+		t.Errorf("targets[0] = %T, want *JSONHTTPTarget", targets[0])
+	}
+}
+
+func TestMaybeStartExporter_NoTargetsIsNoop(t *testing.T) {
+	exporter, done := maybeStartExporter(context.Background(), nil)
+	if exporter == nil {
+		t.Fatalf("expected a non-nil Exporter even with no targets configured")
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected Start() to return an already-closed channel with no targets")
+	}
+}
+
+func TestMaybeStartExporter_WiresFlagConfiguredTarget(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	exporter, done := maybeStartExporter(context.Background(), []string{
+		"--export-push-url", srv.URL,
+		"--export-push-interval", "10ms",
+	})
+
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for hits.Load() < 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := hits.Load(); got < 1 {
+		t.Fatalf("expected at least 1 push to the configured target within %s, got %d", 500*time.Millisecond, got)
+	}
+
+	exporter.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected done channel to close after Stop()")
+	}
+}
+
+// TestStore_RecordResult_NewAndResumeModesMapIdentically asserts RecordResult
+// derives duration/retry metrics from the TaskResult alone, not TaskSpec.Mode.
+func TestStore_RecordResult_NewAndResumeModesMapIdentically(t *testing.T) {
+	for _, mode := range []string{"new", "resume"} {
+		store := NewStore()
+		result := TaskResult{
+			TaskID:   "build-1",
+			ExitCode: 0,
+			Attempts: []AttemptRecord{{Attempt: 1, ExitCode: 1}, {Attempt: 2, ExitCode: 0}},
+		}
+		_ = TaskSpec{ID: "build-1", Mode: mode}
+
+		store.RecordResult("build-1", result, 250*time.Millisecond)
+
+		durations := store.TaskDuration.snapshot()
+		d, ok := durations["build"]
+		if !ok || d.count != 1 {
+			t.Fatalf("mode %q: expected one duration observation under id_prefix=build, got %+v", mode, durations)
+		}
+
+		retries := store.Retries.snapshot()
+		if retries["build"] != 1 {
+			t.Fatalf("mode %q: expected 1 retry recorded, got %+v", mode, retries)
+		}
+	}
+}
+
+func TestStore_RecordTransition_CountsByState(t *testing.T) {
+	store := NewStore()
+	store.RecordTransition("build-1", TaskQueued)
+	store.RecordTransition("build-1", TaskRunning)
+	store.RecordTransition("build-1", TaskSucceeded)
+	store.RecordTransition("build-2", TaskFailed)
+
+	counts := store.StateCounts.snapshot()
+	if counts[TaskSucceeded] != 1 || counts[TaskFailed] != 1 || counts[TaskRunning] != 1 || counts[TaskQueued] != 1 {
+		t.Fatalf("unexpected state counts: %+v", counts)
+	}
+}
+
+func TestPrometheusPushGatewayTarget_Push(t *testing.T) {
+	var received string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 8192)
+		n, _ := r.Body.Read(buf)
+		received = string(buf[:n])
+	}))
+	defer srv.Close()
+
+	store := NewStore()
+	store.RecordTransition("build-1", TaskSucceeded)
+	store.RecordResult("build-1", TaskResult{TaskID: "build-1"}, 100*time.Millisecond)
+
+	target := &PrometheusPushGatewayTarget{URL: srv.URL}
+	if err := target.Push(context.Background(), store, exporterOpts{}); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if received == "" {
+		t.Fatalf("expected the pushgateway target to receive a body")
+	}
+}
+
+func TestJSONHTTPTarget_Push(t *testing.T) {
+	var gotContentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotContentType = r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	store := NewStore()
+	store.RecordTransition("build-1", TaskSucceeded)
+
+	target := &JSONHTTPTarget{URL: srv.URL}
+	if err := target.Push(context.Background(), store, exporterOpts{}); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if gotContentType != "application/json" {
+		t.Fatalf("expected application/json content type, got %q", gotContentType)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// leakGoroutineSlack allows this many more goroutines at suite end than were
+// running at suite start before TestMain reports a leak. Background timers
+// and the runtime's own housekeeping goroutines make an exact match too
+// strict; a genuine leaked logger/mill worker shows up as a steady climb far
+// past this.
+const leakGoroutineSlack = 3
+
+// TestMain installs a package-wide goroutine-leak detector: it snapshots
+// runtime.NumGoroutine() before and after the suite runs, so a future change
+// that leaks a Logger worker or mill goroutine fails loudly here instead of
+// only showing up as flakiness in an unrelated test.
+func TestMain(m *testing.M) {
+	before := settledGoroutineCount()
+	code := m.Run()
+	after := settledGoroutineCount()
+
+	if after > before+leakGoroutineSlack {
+		fmt.Fprintf(os.Stderr, "goroutine leak detected: %d before suite, %d after (stacks follow)\n", before, after)
+		buf := make([]byte, 1<<20)
+		n := runtime.Stack(buf, true)
+		fmt.Fprint(os.Stderr, string(buf[:n]))
+		if code == 0 {
+			code = 1
+		}
+	}
+
+	os.Exit(code)
+}
+
+// settledGoroutineCount gives background goroutines (GC, finalizers, timers
+// winding down) a moment to settle before snapshotting runtime.NumGoroutine,
+// so transient scheduling noise doesn't look like a leak.
+func settledGoroutineCount() int {
+	runtime.GC()
+	time.Sleep(100 * time.Millisecond)
+	return runtime.NumGoroutine()
+}
@@ -2,24 +2,531 @@ package main
 
 import (
 	"bufio"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log/syslog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 )
 
-// Logger writes log messages asynchronously to a temp file.
-// It is intentionally minimal: a buffered channel + single worker goroutine
-// to avoid contention while keeping ordering guarantees.
-type Logger struct {
-	path      string
-	file      *os.File
-	writer    *bufio.Writer
+// Field is a single structured key/value pair attached to a log entry.
+type Field struct {
+	Key   string
+	Value any
+}
+
+type logEntry struct {
+	level  string
+	msg    string
+	time   time.Time
+	pid    int
+	fields []Field
+}
+
+// Level orders log severities for per-sink filtering.
+type Level int
+
+// Severity levels, lowest to highest.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func levelOf(s string) Level {
+	switch s {
+	case "DEBUG":
+		return LevelDebug
+	case "WARN":
+		return LevelWarn
+	case "ERROR":
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// Formatter renders a log entry to bytes suitable for a Sink.
+type Formatter interface {
+	Format(entry logEntry) []byte
+}
+
+type textFormatter struct{}
+
+// Format renders the original bracketed text layout, followed by any
+// structured fields as space-separated key=value pairs.
+func (textFormatter) Format(e logEntry) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] [PID:%d] %s: %s", e.time.Format("2006-01-02 15:04:05.000"), e.pid, e.level, e.msg)
+	for _, f := range e.fields {
+		fmt.Fprintf(&sb, " %s=%v", f.Key, f.Value)
+	}
+	sb.WriteByte('\n')
+	return []byte(sb.String())
+}
+
+type jsonFormatter struct{}
+
+// Format renders the entry as a single JSON line, suitable for log shippers.
+func (jsonFormatter) Format(e logEntry) []byte {
+	m := make(map[string]any, len(e.fields)+4)
+	m["time"] = e.time.Format(time.RFC3339Nano)
+	m["level"] = e.level
+	m["pid"] = e.pid
+	m["msg"] = e.msg
+	for _, f := range e.fields {
+		m[f.Key] = f.Value
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return []byte(fmt.Sprintf(`{"level":"ERROR","msg":"failed to marshal log entry: %s"}`, err) + "\n")
+	}
+	return append(b, '\n')
+}
+
+// TextFormatter renders the bracketed plain-text format (the default).
+var TextFormatter Formatter = textFormatter{}
+
+// JSONFormatter renders one JSON object per line.
+var JSONFormatter Formatter = jsonFormatter{}
+
+type logfmtFormatter struct{}
+
+// Format renders the entry as a single logfmt line: space-separated
+// key=value pairs, quoting any value containing a space.
+func (logfmtFormatter) Format(e logEntry) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "time=%s level=%s pid=%d msg=%s", e.time.Format(time.RFC3339Nano), e.level, e.pid, logfmtValue(e.msg))
+	for _, f := range e.fields {
+		fmt.Fprintf(&sb, " %s=%s", f.Key, logfmtValue(fmt.Sprintf("%v", f.Value)))
+	}
+	sb.WriteByte('\n')
+	return []byte(sb.String())
+}
+
+func logfmtValue(v string) string {
+	if strings.ContainsAny(v, " \t\"=") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// LogfmtFormatter renders entries as logfmt (space-separated key=value
+// pairs), the format preferred by most log aggregators over the bracketed
+// TextFormatter.
+var LogfmtFormatter Formatter = logfmtFormatter{}
+
+// Sink is a log destination. Write receives the entry's level (so
+// level-aware sinks like syslog can pick the right priority) and the bytes
+// already rendered by the sink's configured Formatter.
+type Sink interface {
+	Write(level string, formatted []byte) error
+}
+
+type flusher interface{ Flush() error }
+type syncer interface{ Sync() error }
+
+type sinkBinding struct {
+	sink      Sink
+	formatter Formatter
+	minLevel  Level
+}
+
+// fileSink is the default sink: a size-rotated, append-only file under
+// os.TempDir(). It implements Sink, flusher, syncer and io.Closer.
+type fileSink struct {
+	path   string
+	suffix string
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+
+	backupsMu sync.Mutex
+	backups   []string
+
+	// millCh feeds rotated backup paths to the single mill goroutine, which
+	// compresses (if enabled) and prunes them off the hot rotate() path.
+	// It is started eagerly in newFileSink rather than lazily on first
+	// rotation, so Close can always close it and deterministically wait on
+	// millDone - a lazily-started mill would never receive the close if no
+	// rotation ever happened, leaking the goroutine.
+	millCh   chan string
+	millDone chan struct{}
+}
+
+func newFileSink(path, suffix string, f *os.File) *fileSink {
+	var startSize int64
+	if info, err := f.Stat(); err == nil {
+		startSize = info.Size()
+	}
+	fs := &fileSink{
+		path:     path,
+		suffix:   suffix,
+		file:     f,
+		writer:   bufio.NewWriterSize(f, 4096),
+		size:     startSize,
+		millCh:   make(chan string, 100),
+		millDone: make(chan struct{}),
+	}
+	go fs.mill()
+	return fs
+}
+
+func (fs *fileSink) Write(_ string, p []byte) error {
+	n, err := fs.writer.Write(p)
+	fs.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if fs.maxSize > 0 && fs.size >= fs.maxSize {
+		return fs.rotate()
+	}
+	return nil
+}
+
+func (fs *fileSink) Flush() error { return fs.writer.Flush() }
+func (fs *fileSink) Sync() error  { return fs.file.Sync() }
+
+func (fs *fileSink) Close() error {
+	close(fs.millCh)
+	<-fs.millDone
+
+	var closeErr error
+	if err := fs.writer.Flush(); err != nil {
+		closeErr = err
+	}
+	if err := fs.file.Sync(); err != nil && closeErr == nil {
+		closeErr = err
+	}
+	if err := fs.file.Close(); err != nil && closeErr == nil {
+		closeErr = err
+	}
+	return closeErr
+}
+
+// Backups returns the paths of rotated log files still on disk, oldest first.
+func (fs *fileSink) Backups() []string {
+	fs.backupsMu.Lock()
+	defer fs.backupsMu.Unlock()
+	out := make([]string, len(fs.backups))
+	copy(out, fs.backups)
+	return out
+}
+
+// rotate flushes and closes the current primary file, renames it to a
+// timestamped backup, and reopens a fresh primary file in its place. It is
+// only ever called from the core's run() goroutine, via Write's size check
+// or a Logger.Rotate() request, so it never races with the ticker or
+// flush-request handling in that same select loop.
+func (fs *fileSink) rotate() error {
+	if err := fs.writer.Flush(); err != nil {
+		return err
+	}
+	if err := fs.file.Close(); err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(fs.path), ".log")
+	// Sub-second precision avoids two rotations within the same wall-clock
+	// second colliding on one backup name, which would silently clobber the
+	// earlier backup on rename.
+	backupName := fmt.Sprintf("%s-%s.log", base, time.Now().UTC().Format("20060102T150405.000000000Z"))
+	backupPath := filepath.Join(filepath.Dir(fs.path), backupName)
+
+	if err := os.Rename(fs.path, backupPath); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(fs.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	fs.file = f
+	fs.writer = bufio.NewWriterSize(f, 4096)
+	fs.size = 0
+
+	fs.backupsMu.Lock()
+	fs.backups = append(fs.backups, backupPath)
+	fs.backupsMu.Unlock()
+
+	fs.millCh <- backupPath
+
+	return nil
+}
+
+// mill is the sink's single long-lived background worker: it receives each
+// freshly rotated backup path from millCh (already recorded in fs.backups
+// by rotate) and compresses it if WithCompress is set, then prunes old
+// backups - all off the hot rotate() path so log writes are never slowed
+// down by disk I/O.
+func (fs *fileSink) mill() {
+	defer close(fs.millDone)
+
+	for path := range fs.millCh {
+		if fs.compress {
+			if gzPath, err := gzipFile(path); err == nil {
+				os.Remove(path)
+				fs.backupsMu.Lock()
+				for i, p := range fs.backups {
+					if p == path {
+						fs.backups[i] = gzPath
+						break
+					}
+				}
+				fs.backupsMu.Unlock()
+			}
+		}
+
+		fs.pruneBackups()
+	}
+}
+
+// gzipFile compresses path to path+".gz" and returns the new path, used by
+// mill when WithCompress is set.
+func gzipFile(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	gzPath := path + ".gz"
+	out, err := os.OpenFile(gzPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	if _, err := io.Copy(gw, in); err != nil {
+		gw.Close()
+		os.Remove(gzPath)
+		return "", err
+	}
+	if err := gw.Close(); err != nil {
+		os.Remove(gzPath)
+		return "", err
+	}
+	return gzPath, nil
+}
+
+// pruneBackups removes rotated backups older than MaxAge and, beyond that,
+// any excess past MaxBackups (oldest first). Only ever called from mill, so
+// it never runs concurrently with itself.
+func (fs *fileSink) pruneBackups() {
+	fs.backupsMu.Lock()
+	defer fs.backupsMu.Unlock()
+
+	kept := fs.backups[:0:0]
+	now := time.Now()
+	for _, path := range fs.backups {
+		if fs.maxAge > 0 {
+			if info, err := os.Stat(path); err == nil && now.Sub(info.ModTime()) > fs.maxAge {
+				os.Remove(path)
+				continue
+			}
+		}
+		kept = append(kept, path)
+	}
+
+	if fs.maxBackups > 0 && len(kept) > fs.maxBackups {
+		excess := len(kept) - fs.maxBackups
+		for _, path := range kept[:excess] {
+			os.Remove(path)
+		}
+		kept = kept[excess:]
+	}
+
+	fs.backups = kept
+}
+
+// stderrSink writes directly to os.Stderr, unbuffered.
+type stderrSink struct{}
+
+// NewStderrSink returns a Sink that writes straight to os.Stderr.
+func NewStderrSink() Sink { return stderrSink{} }
+
+func (stderrSink) Write(_ string, p []byte) error {
+	_, err := os.Stderr.Write(p)
+	return err
+}
+
+// MemorySink keeps formatted entries in memory; handy for tests and simple
+// in-process inspection.
+type MemorySink struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+// NewMemorySink returns an empty MemorySink.
+func NewMemorySink() *MemorySink { return &MemorySink{} }
+
+func (s *MemorySink) Write(_ string, p []byte) error {
+	s.mu.Lock()
+	s.entries = append(s.entries, string(p))
+	s.mu.Unlock()
+	return nil
+}
+
+// Entries returns a copy of the formatted lines written so far.
+func (s *MemorySink) Entries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, len(s.entries))
+	copy(out, s.entries)
+	return out
+}
+
+// SyslogSink forwards entries to the local syslog daemon, mapping levels to
+// the matching syslog severity.
+type SyslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslogSink dials syslog with the given facility/severity priority and tag.
+func NewSyslogSink(priority syslog.Priority, tag string) (*SyslogSink, error) {
+	w, err := syslog.New(priority, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{w: w}, nil
+}
+
+func (s *SyslogSink) Write(level string, p []byte) error {
+	msg := strings.TrimSuffix(string(p), "\n")
+	switch level {
+	case "ERROR":
+		return s.w.Err(msg)
+	case "WARN":
+		return s.w.Warning(msg)
+	case "DEBUG":
+		return s.w.Debug(msg)
+	default:
+		return s.w.Info(msg)
+	}
+}
+
+func (s *SyslogSink) Close() error { return s.w.Close() }
+
+// defaultRingBufferSize is the number of recent formatted entries mirrored
+// in memory for Tail/Subscribe when no WithRingBufferSize option is given.
+const defaultRingBufferSize = 500
+
+// ringBuffer mirrors the last N formatted log entries in memory so operators
+// and tests can inspect recent activity without reading the log file. On
+// overflow the oldest entry is overwritten. Subscribers that fall behind
+// have entries dropped rather than blocking the worker, counted in dropped.
+type ringBuffer struct {
+	mu   sync.RWMutex
+	data []string
+	next int
+	size int
+	cap  int
+
+	subsMu  sync.Mutex
+	subs    map[int]chan string
+	subSeq  int
+	dropped atomic.Int64
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &ringBuffer{
+		data: make([]string, capacity),
+		cap:  capacity,
+		subs: make(map[int]chan string),
+	}
+}
+
+func (r *ringBuffer) push(line string) {
+	r.mu.Lock()
+	r.data[r.next] = line
+	r.next = (r.next + 1) % r.cap
+	if r.size < r.cap {
+		r.size++
+	}
+	r.mu.Unlock()
+
+	r.subsMu.Lock()
+	for _, ch := range r.subs {
+		select {
+		case ch <- line:
+		default:
+			r.dropped.Add(1)
+		}
+	}
+	r.subsMu.Unlock()
+}
+
+// tail returns the last n entries, oldest first. n <= 0 or n > the number
+// of buffered entries returns everything currently buffered.
+func (r *ringBuffer) tail(n int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if n <= 0 || n > r.size {
+		n = r.size
+	}
+	out := make([]string, n)
+	start := (r.next - n + r.cap) % r.cap
+	for i := 0; i < n; i++ {
+		out[i] = r.data[(start+i)%r.cap]
+	}
+	return out
+}
+
+// subscribe returns a channel that receives every entry pushed after this
+// call, until ctx is done. The channel is closed once ctx is done.
+func (r *ringBuffer) subscribe(ctx context.Context) <-chan string {
+	ch := make(chan string, 64)
+
+	r.subsMu.Lock()
+	id := r.subSeq
+	r.subSeq++
+	r.subs[id] = ch
+	r.subsMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		r.subsMu.Lock()
+		delete(r.subs, id)
+		close(ch)
+		r.subsMu.Unlock()
+	}()
+
+	return ch
+}
+
+// loggerCore holds the state shared by a Logger and every child created via
+// With(); only the sticky fields differ between them.
+type loggerCore struct {
+	file     *fileSink // default sink, kept for Path()/Backups()/RemoveLogFile()
+	sinks    []sinkBinding
+	ring     *ringBuffer
+	minLevel Level // entries below this are dropped before reaching any sink
+
 	ch        chan logEntry
 	flushReq  chan chan struct{}
+	rotateReq chan chan error
 	done      chan struct{}
 	closed    atomic.Bool
 	closeOnce sync.Once
@@ -27,20 +534,157 @@ type Logger struct {
 	pendingWG sync.WaitGroup
 }
 
-type logEntry struct {
-	level string
-	msg   string
+// Logger writes log messages asynchronously, fanning each entry out to a set
+// of sinks. It is intentionally minimal: a buffered channel + single worker
+// goroutine to avoid contention while keeping per-sink ordering guarantees.
+type Logger struct {
+	core   *loggerCore
+	fields []Field
+}
+
+// LoggerOption configures a Logger on construction.
+type LoggerOption func(*Logger)
+
+// WithMaxSize sets the primary log file size (in bytes) that triggers
+// rotation. A value <= 0 disables size-based rotation.
+func WithMaxSize(bytes int64) LoggerOption {
+	return func(l *Logger) { l.core.file.maxSize = bytes }
+}
+
+// WithMaxBackups caps the number of rotated backup files retained; the
+// oldest backups beyond this count are pruned after each rotation. A value
+// <= 0 means unlimited.
+func WithMaxBackups(n int) LoggerOption {
+	return func(l *Logger) { l.core.file.maxBackups = n }
+}
+
+// WithMaxAge prunes rotated backup files older than d after each rotation.
+// A value <= 0 disables age-based pruning.
+func WithMaxAge(d time.Duration) LoggerOption {
+	return func(l *Logger) { l.core.file.maxAge = d }
+}
+
+// WithCompress gzips each rotated backup (producing a ".log.gz" file)
+// before it becomes eligible for age/count pruning.
+func WithCompress() LoggerOption {
+	return func(l *Logger) { l.core.file.compress = true }
+}
+
+// WithFormatter overrides the formatter used by the default file sink (for
+// example JSONFormatter for structured output).
+func WithFormatter(f Formatter) LoggerOption {
+	return func(l *Logger) { l.core.sinks[0].formatter = f }
+}
+
+// WithSink fans entries out to an additional sink, formatted with formatter
+// and filtered to entries at or above minLevel.
+func WithSink(sink Sink, formatter Formatter, minLevel Level) LoggerOption {
+	return func(l *Logger) {
+		l.core.sinks = append(l.core.sinks, sinkBinding{sink: sink, formatter: formatter, minLevel: minLevel})
+	}
+}
+
+// WithMinLevel drops entries below level before they reach the worker
+// goroutine or any sink, unlike the per-sink minLevel in WithSink which only
+// gates that one sink. Default is LevelDebug (everything passes).
+func WithMinLevel(level Level) LoggerOption {
+	return func(l *Logger) { l.core.minLevel = level }
+}
+
+// WithRingBufferSize overrides the number of recent formatted entries kept
+// in memory for Tail/Subscribe (default defaultRingBufferSize).
+func WithRingBufferSize(n int) LoggerOption {
+	return func(l *Logger) { l.core.ring = newRingBuffer(n) }
 }
 
 // NewLogger creates the async logger and starts the worker goroutine.
 // The log file is created under os.TempDir() using the required naming scheme.
-func NewLogger() (*Logger, error) {
-	return NewLoggerWithSuffix("")
+func NewLogger(opts ...LoggerOption) (*Logger, error) {
+	return NewLoggerWithSuffix("", opts...)
+}
+
+// levelFromEnv reads envVar (one of "debug", "info", "warn" or "error",
+// case-insensitive) and returns the matching Level, or def when the variable
+// is unset or holds an unrecognized value.
+func levelFromEnv(envVar string, def Level) Level {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(envVar))) {
+	case "debug":
+		return LevelDebug
+	case "info":
+		return LevelInfo
+	case "warn":
+		return LevelWarn
+	case "error":
+		return LevelError
+	default:
+		return def
+	}
+}
+
+// logLevelFromEnv reads CODEX_WRAPPER_LOG, defaulting to LevelInfo, so a sink
+// gated on this level (the optional stderr echo sink, see stderrSinkOpts)
+// sees INFO and above by default.
+func logLevelFromEnv() Level {
+	return levelFromEnv("CODEX_WRAPPER_LOG", LevelInfo)
+}
+
+// codexLogLevelFromEnv reads CODEX_LOG_LEVEL, defaulting to LevelDebug so the
+// default file sink keeps everything unless an operator narrows it.
+func codexLogLevelFromEnv() Level {
+	return levelFromEnv("CODEX_LOG_LEVEL", LevelDebug)
+}
+
+// logFormatFromEnv reads CODEX_LOG_FORMAT ("json" or "logfmt",
+// case-insensitive) and returns the matching Formatter for the default sink.
+// Any other value, including unset, keeps the existing bracketed
+// TextFormatter, so setting the variable is strictly additive.
+func logFormatFromEnv() Formatter {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("CODEX_LOG_FORMAT"))) {
+	case "json":
+		return JSONFormatter
+	case "logfmt":
+		return LogfmtFormatter
+	default:
+		return TextFormatter
+	}
+}
+
+// logRotationOptsFromEnv reads CODEX_LOG_MAX_SIZE (bytes), CODEX_LOG_MAX_AGE
+// (seconds), CODEX_LOG_MAX_BACKUPS (count) and CODEX_LOG_COMPRESS
+// ("1"/"true") and returns the matching LoggerOptions, skipping any variable
+// that is unset or fails to parse so NewLogger's caller only has to append
+// the result rather than branch on each one individually.
+func logRotationOptsFromEnv() []LoggerOption {
+	var opts []LoggerOption
+
+	if raw := os.Getenv("CODEX_LOG_MAX_SIZE"); raw != "" {
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil && n > 0 {
+			opts = append(opts, WithMaxSize(n))
+		}
+	}
+	if raw := os.Getenv("CODEX_LOG_MAX_AGE"); raw != "" {
+		if secs, err := strconv.Atoi(raw); err == nil && secs > 0 {
+			opts = append(opts, WithMaxAge(time.Duration(secs)*time.Second))
+		}
+	}
+	if raw := os.Getenv("CODEX_LOG_MAX_BACKUPS"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			opts = append(opts, WithMaxBackups(n))
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("CODEX_LOG_COMPRESS"))) {
+	case "1", "true", "yes":
+		opts = append(opts, WithCompress())
+	}
+
+	return opts
 }
 
 // NewLoggerWithSuffix creates a logger with an optional suffix in the filename.
 // Useful for tests that need isolated log files within the same process.
-func NewLoggerWithSuffix(suffix string) (*Logger, error) {
+// Rotation is disabled by default; pass WithMaxSize/WithMaxBackups/WithMaxAge
+// to enable it. Additional sinks can be attached with WithSink.
+func NewLoggerWithSuffix(suffix string, opts ...LoggerOption) (*Logger, error) {
 	filename := fmt.Sprintf("codex-wrapper-%d", os.Getpid())
 	if suffix != "" {
 		filename += "-" + suffix
@@ -54,42 +698,124 @@ func NewLoggerWithSuffix(suffix string) (*Logger, error) {
 		return nil, err
 	}
 
-	l := &Logger{
-		path:     path,
-		file:     f,
-		writer:   bufio.NewWriterSize(f, 4096),
-		ch:       make(chan logEntry, 1000),
-		flushReq: make(chan chan struct{}, 1),
-		done:     make(chan struct{}),
+	fs := newFileSink(path, suffix, f)
+
+	core := &loggerCore{
+		file:      fs,
+		sinks:     []sinkBinding{{sink: fs, formatter: logFormatFromEnv(), minLevel: codexLogLevelFromEnv()}},
+		ring:      newRingBuffer(defaultRingBufferSize),
+		ch:        make(chan logEntry, 1000),
+		flushReq:  make(chan chan struct{}, 1),
+		rotateReq: make(chan chan error, 1),
+		done:      make(chan struct{}),
+	}
+
+	l := &Logger{core: core}
+	for _, opt := range opts {
+		opt(l)
 	}
 
-	l.workerWG.Add(1)
-	go l.run()
+	core.workerWG.Add(1)
+	go core.run()
 
 	return l, nil
 }
 
-// Path returns the underlying log file path (useful for tests/inspection).
+// With returns a child Logger that shares the parent's sinks and worker but
+// sticks kv (alternating key, value, ...) onto every subsequent log call.
+func (l *Logger) With(kv ...any) *Logger {
+	if l == nil {
+		return nil
+	}
+	merged := make([]Field, 0, len(l.fields)+len(kv)/2+1)
+	merged = append(merged, l.fields...)
+	merged = append(merged, fieldsFromKV(kv)...)
+	return &Logger{core: l.core, fields: merged}
+}
+
+// New is an alias for With, named to match the log15-style convention of
+// deriving a bound child logger (logger.New("thread_id", id)) rather than
+// decorating the call site's message text.
+func (l *Logger) New(kv ...any) *Logger { return l.With(kv...) }
+
+func fieldsFromKV(kv []any) []Field {
+	if len(kv) == 0 {
+		return nil
+	}
+	fields := make([]Field, 0, (len(kv)+1)/2)
+	i := 0
+	for ; i+1 < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprintf("%v", kv[i])
+		}
+		fields = append(fields, Field{Key: key, Value: kv[i+1]})
+	}
+	if i < len(kv) {
+		fields = append(fields, Field{Key: "EXTRA", Value: kv[i]})
+	}
+	return fields
+}
+
+// Path returns the default sink's log file path (useful for tests/inspection).
 func (l *Logger) Path() string {
 	if l == nil {
 		return ""
 	}
-	return l.path
+	return l.core.file.path
+}
+
+// Backups returns the paths of rotated log files still on disk, oldest first.
+func (l *Logger) Backups() []string {
+	if l == nil {
+		return nil
+	}
+	return l.core.file.Backups()
 }
 
-// Info logs at INFO level.
-func (l *Logger) Info(msg string) { l.log("INFO", msg) }
+// Tail returns the last n formatted entries written to the default sink,
+// oldest first. n <= 0 returns everything currently buffered.
+func (l *Logger) Tail(n int) []string {
+	if l == nil {
+		return nil
+	}
+	return l.core.ring.tail(n)
+}
 
-// Warn logs at WARN level.
-func (l *Logger) Warn(msg string) { l.log("WARN", msg) }
+// Subscribe returns a channel that receives every formatted entry written to
+// the default sink after this call, until ctx is done. Slow subscribers have
+// entries dropped (see Dropped) rather than blocking the logger worker.
+func (l *Logger) Subscribe(ctx context.Context) <-chan string {
+	if l == nil {
+		ch := make(chan string)
+		close(ch)
+		return ch
+	}
+	return l.core.ring.subscribe(ctx)
+}
 
-// Debug logs at DEBUG level.
-func (l *Logger) Debug(msg string) { l.log("DEBUG", msg) }
+// Dropped returns the number of entries dropped across all subscribers
+// because they fell behind.
+func (l *Logger) Dropped() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.core.ring.dropped.Load()
+}
+
+// Info logs at INFO level with optional structured fields (alternating key, value, ...).
+func (l *Logger) Info(msg string, kv ...any) { l.log("INFO", msg, kv...) }
+
+// Warn logs at WARN level with optional structured fields.
+func (l *Logger) Warn(msg string, kv ...any) { l.log("WARN", msg, kv...) }
+
+// Debug logs at DEBUG level with optional structured fields.
+func (l *Logger) Debug(msg string, kv ...any) { l.log("DEBUG", msg, kv...) }
 
-// Error logs at ERROR level.
-func (l *Logger) Error(msg string) { l.log("ERROR", msg) }
+// Error logs at ERROR level with optional structured fields.
+func (l *Logger) Error(msg string, kv ...any) { l.log("ERROR", msg, kv...) }
 
-// Close stops the worker and syncs the log file.
+// Close stops the worker and closes every sink that implements io.Closer.
 // The log file is NOT removed, allowing inspection after program exit.
 // It is safe to call multiple times.
 // Returns after a 5-second timeout if worker doesn't stop gracefully.
@@ -98,17 +824,26 @@ func (l *Logger) Close() error {
 		return nil
 	}
 
+	c := l.core
 	var closeErr error
 
-	l.closeOnce.Do(func() {
-		l.closed.Store(true)
-		close(l.done)
-		close(l.ch)
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		close(c.done)
+
+		// A log() call that read closed==false just before the line above
+		// may still be blocked in its "c.ch <- entry / <-c.done" select;
+		// waiting for pendingWG here lets it resolve (via the now-closed
+		// done case) before ch is closed, so that select can never still
+		// be sitting on a send to an already-closed channel, which would
+		// panic rather than safely fall through to the done case.
+		c.pendingWG.Wait()
+		close(c.ch)
 
 		// Wait for worker with timeout
 		workerDone := make(chan struct{})
 		go func() {
-			l.workerWG.Wait()
+			c.workerWG.Wait()
 			close(workerDone)
 		}()
 
@@ -120,16 +855,12 @@ func (l *Logger) Close() error {
 			closeErr = fmt.Errorf("logger worker timeout during close")
 		}
 
-		if err := l.writer.Flush(); err != nil && closeErr == nil {
-			closeErr = err
-		}
-
-		if err := l.file.Sync(); err != nil && closeErr == nil {
-			closeErr = err
-		}
-
-		if err := l.file.Close(); err != nil && closeErr == nil {
-			closeErr = err
+		for _, sb := range c.sinks {
+			if cl, ok := sb.sink.(io.Closer); ok {
+				if err := cl.Close(); err != nil && closeErr == nil {
+					closeErr = err
+				}
+			}
 		}
 
 		// Log file is kept for debugging - NOT removed
@@ -139,12 +870,12 @@ func (l *Logger) Close() error {
 	return closeErr
 }
 
-// RemoveLogFile removes the log file. Should only be called after Close().
+// RemoveLogFile removes the default sink's log file. Should only be called after Close().
 func (l *Logger) RemoveLogFile() error {
 	if l == nil {
 		return nil
 	}
-	return os.Remove(l.path)
+	return os.Remove(l.core.file.path)
 }
 
 // Flush waits for all pending log entries to be written. Primarily for tests.
@@ -154,10 +885,12 @@ func (l *Logger) Flush() {
 		return
 	}
 
+	c := l.core
+
 	// Wait for pending entries with timeout
 	done := make(chan struct{})
 	go func() {
-		l.pendingWG.Wait()
+		c.pendingWG.Wait()
 		close(done)
 	}()
 
@@ -175,7 +908,7 @@ func (l *Logger) Flush() {
 	// Trigger writer flush
 	flushDone := make(chan struct{})
 	select {
-	case l.flushReq <- flushDone:
+	case c.flushReq <- flushDone:
 		// Wait for flush to complete
 		select {
 		case <-flushDone:
@@ -183,61 +916,159 @@ func (l *Logger) Flush() {
 		case <-time.After(1 * time.Second):
 			// Flush timeout
 		}
-	case <-l.done:
+	case <-c.done:
 		// Logger is closing
 	case <-time.After(1 * time.Second):
 		// Timeout sending flush request
 	}
 }
 
-func (l *Logger) log(level, msg string) {
+// Rotate forces the default file sink to rotate immediately, the same way
+// crossing MaxSize would, so SIGUSR1 (see installLogRotateHandler) lets an
+// external log shipper (logrotate, Vector) safely reopen the file without
+// racing the worker goroutine's own size-triggered rotate() call - both go
+// through this same rotateReq request/response round trip into run().
+// Returns after a 5-second timeout if the worker doesn't respond in time.
+func (l *Logger) Rotate() error {
+	if l == nil {
+		return nil
+	}
+	c := l.core
+
+	resultCh := make(chan error, 1)
+	select {
+	case c.rotateReq <- resultCh:
+	case <-c.done:
+		return fmt.Errorf("logger is closing")
+	case <-time.After(1 * time.Second):
+		return fmt.Errorf("timed out requesting rotation")
+	}
+
+	select {
+	case err := <-resultCh:
+		return err
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("timed out waiting for rotation")
+	}
+}
+
+// logRotateFn performs the actual rotation triggered by
+// installLogRotateHandler; a var (not a direct l.Rotate() call) so tests can
+// swap in a spy without waiting on a real SIGUSR1 round trip.
+var logRotateFn = func(l *Logger) error { return l.Rotate() }
+
+// installLogRotateHandler watches for SIGUSR1 and forces logger to rotate
+// its log file on each delivery, independently of installDebugDumpHandler
+// (also SIGUSR1-triggered) - both signal.Notify the same signal on their own
+// channel, so a single SIGUSR1 both dumps diagnostics and reopens the log
+// file for a log shipper (logrotate, Vector) to manage safely. The returned
+// stop func releases the signal handler and must be deferred by the caller.
+func installLogRotateHandler(logger *Logger) (stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				if err := logRotateFn(logger); err != nil {
+					logger.Warn(fmt.Sprintf("SIGUSR1 rotation failed: %v", err))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
+
+func (l *Logger) log(level, msg string, kv ...any) {
 	if l == nil {
 		return
 	}
-	if l.closed.Load() {
+	c := l.core
+	if c.closed.Load() {
+		return
+	}
+	if levelOf(level) < c.minLevel {
 		return
 	}
 
-	entry := logEntry{level: level, msg: msg}
-	l.pendingWG.Add(1)
+	fields := append(append([]Field(nil), l.fields...), fieldsFromKV(kv)...)
+	entry := logEntry{level: level, msg: msg, time: time.Now(), pid: os.Getpid(), fields: fields}
+	c.pendingWG.Add(1)
 
 	select {
-	case l.ch <- entry:
+	case c.ch <- entry:
 		// Successfully sent to channel
-	case <-l.done:
+	case <-c.done:
 		// Logger is closing, drop this entry
-		l.pendingWG.Done()
+		c.pendingWG.Done()
 		return
 	}
 }
 
-func (l *Logger) run() {
-	defer l.workerWG.Done()
+func (c *loggerCore) run() {
+	defer c.workerWG.Done()
 
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case entry, ok := <-l.ch:
+		case entry, ok := <-c.ch:
 			if !ok {
 				// Channel closed, final flush
-				l.writer.Flush()
+				for _, sb := range c.sinks {
+					if f, ok := sb.sink.(flusher); ok {
+						f.Flush()
+					}
+				}
 				return
 			}
-			timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-			pid := os.Getpid()
-			fmt.Fprintf(l.writer, "[%s] [PID:%d] %s: %s\n", timestamp, pid, entry.level, entry.msg)
-			l.pendingWG.Done()
+			for i, sb := range c.sinks {
+				if levelOf(entry.level) < sb.minLevel {
+					continue
+				}
+				formatted := sb.formatter.Format(entry)
+				if err := sb.sink.Write(entry.level, formatted); err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: log sink write failed: %v\n", err)
+				}
+				if i == 0 && c.ring != nil {
+					c.ring.push(string(formatted))
+				}
+			}
+			c.pendingWG.Done()
 
 		case <-ticker.C:
-			l.writer.Flush()
+			for _, sb := range c.sinks {
+				if f, ok := sb.sink.(flusher); ok {
+					f.Flush()
+				}
+			}
 
-		case flushDone := <-l.flushReq:
-			// Explicit flush request - flush writer and sync to disk
-			l.writer.Flush()
-			l.file.Sync()
+		case flushDone := <-c.flushReq:
+			// Explicit flush request - flush and sync every sink that supports it
+			for _, sb := range c.sinks {
+				if f, ok := sb.sink.(flusher); ok {
+					f.Flush()
+				}
+				if s, ok := sb.sink.(syncer); ok {
+					s.Sync()
+				}
+			}
 			close(flushDone)
+
+		case resultCh := <-c.rotateReq:
+			// Routed through this same goroutine as size-triggered rotation
+			// (see fileSink.rotate's doc comment), so a forced Rotate() call
+			// never races a Write-triggered one.
+			resultCh <- c.file.rotate()
 		}
 	}
 }
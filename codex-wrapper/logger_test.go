@@ -2,12 +2,15 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -33,19 +36,82 @@ func TestLoggerCreatesFileWithPID(t *testing.T) {
 }
 
 func TestLoggerWritesLevels(t *testing.T) {
+	cases := []struct {
+		name      string
+		formatter Formatter
+		check     func(t *testing.T, content string)
+	}{
+		{
+			name:      "text",
+			formatter: TextFormatter,
+			check: func(t *testing.T, content string) {
+				for _, c := range []string{"INFO: info message", "WARN: warn message", "DEBUG: debug message", "ERROR: error message"} {
+					if !strings.Contains(content, c) {
+						t.Fatalf("log file missing entry %q, content: %s", c, content)
+					}
+				}
+			},
+		},
+		{
+			name:      "json",
+			formatter: JSONFormatter,
+			check: func(t *testing.T, content string) {
+				levels := map[string]string{"info message": "INFO", "warn message": "WARN", "debug message": "DEBUG", "error message": "ERROR"}
+				for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+					var decoded map[string]any
+					if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+						t.Fatalf("expected valid JSON line, got %q: %v", line, err)
+					}
+					if decoded["level"] != levels[decoded["msg"].(string)] {
+						t.Fatalf("unexpected level for entry %+v", decoded)
+					}
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			t.Setenv("TMPDIR", tempDir)
+
+			logger, err := NewLoggerWithSuffix(tc.name, WithFormatter(tc.formatter))
+			if err != nil {
+				t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+			}
+			defer logger.Close()
+
+			logger.Info("info message")
+			logger.Warn("warn message")
+			logger.Debug("debug message")
+			logger.Error("error message")
+
+			logger.Flush()
+
+			data, err := os.ReadFile(logger.Path())
+			if err != nil {
+				t.Fatalf("failed to read log file: %v", err)
+			}
+
+			tc.check(t, string(data))
+		})
+	}
+}
+
+func TestLoggerMinLevelDropsEntriesAtSource(t *testing.T) {
 	tempDir := t.TempDir()
 	t.Setenv("TMPDIR", tempDir)
 
-	logger, err := NewLogger()
+	logger, err := NewLogger(WithMinLevel(LevelWarn))
 	if err != nil {
 		t.Fatalf("NewLogger() error = %v", err)
 	}
 	defer logger.Close()
 
-	logger.Info("info message")
-	logger.Warn("warn message")
-	logger.Debug("debug message")
-	logger.Error("error message")
+	logger.Debug("dropped debug message")
+	logger.Info("dropped info message")
+	logger.Warn("kept warn message")
+	logger.Error("kept error message")
 
 	logger.Flush()
 
@@ -55,10 +121,14 @@ func TestLoggerWritesLevels(t *testing.T) {
 	}
 
 	content := string(data)
-	checks := []string{"INFO: info message", "WARN: warn message", "DEBUG: debug message", "ERROR: error message"}
-	for _, c := range checks {
-		if !strings.Contains(content, c) {
-			t.Fatalf("log file missing entry %q, content: %s", c, content)
+	for _, dropped := range []string{"dropped debug message", "dropped info message"} {
+		if strings.Contains(content, dropped) {
+			t.Fatalf("expected %q to be filtered at the source, content: %s", dropped, content)
+		}
+	}
+	for _, kept := range []string{"kept warn message", "kept error message"} {
+		if !strings.Contains(content, kept) {
+			t.Fatalf("expected %q to be present, content: %s", kept, content)
 		}
 	}
 }
@@ -91,7 +161,7 @@ func TestLoggerCloseRemovesFileAndStopsWorker(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		logger.workerWG.Wait()
+		logger.core.workerWG.Wait()
 		close(done)
 	}()
 
@@ -100,6 +170,16 @@ func TestLoggerCloseRemovesFileAndStopsWorker(t *testing.T) {
 	case <-time.After(200 * time.Millisecond):
 		t.Fatalf("worker goroutine did not exit after Close")
 	}
+
+	// The file sink's mill goroutine starts eagerly (not lazily on first
+	// rotation) precisely so that Close, which always closes millCh, always
+	// has a goroutine on the other end to receive it - otherwise a run with
+	// no rotation would leak it.
+	select {
+	case <-logger.core.file.millDone:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("mill goroutine did not exit after Close")
+	}
 }
 
 func TestLoggerConcurrentWritesSafe(t *testing.T) {
@@ -151,13 +231,49 @@ func TestLoggerConcurrentWritesSafe(t *testing.T) {
 	}
 }
 
+// TestLoggerCloseDuringConcurrentWrites fuzzes Close racing with in-flight
+// Info calls: writers keep logging right up until Close returns, proving the
+// async worker drains (or safely drops) every admitted entry instead of
+// panicking on a send to an already-closed channel.
+func TestLoggerCloseDuringConcurrentWrites(t *testing.T) {
+	const writers = 10
+	const iterations = 20
+
+	for i := 0; i < iterations; i++ {
+		tempDir := t.TempDir()
+		t.Setenv("TMPDIR", tempDir)
+
+		logger, err := NewLoggerWithSuffix(fmt.Sprintf("closefuzz%d", i))
+		if err != nil {
+			t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(writers)
+		for w := 0; w < writers; w++ {
+			go func(id int) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					logger.Info(fmt.Sprintf("w%d-%d", id, j))
+				}
+			}(w)
+		}
+
+		if err := logger.Close(); err != nil {
+			t.Errorf("Close() returned error: %v", err)
+		}
+
+		wg.Wait()
+	}
+}
+
 func TestLoggerTerminateProcessActive(t *testing.T) {
 	cmd := exec.Command("sleep", "5")
 	if err := cmd.Start(); err != nil {
 		t.Skipf("cannot start sleep command: %v", err)
 	}
 
-	timer := terminateProcess(cmd)
+	timer := terminateProcess(cmd, time.Duration(forceKillDelay)*time.Second)
 	if timer == nil {
 		t.Fatalf("terminateProcess returned nil timer for active process")
 	}
@@ -184,3 +300,551 @@ func TestLoggerTerminateProcessActive(t *testing.T) {
 func TestLoggerCoverageSuite(t *testing.T) {
 	TestParseJSONStream_CoverageSuite(t)
 }
+
+func TestLoggerRotatesOnMaxSize(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLoggerWithSuffix("rotate", WithMaxSize(200))
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	for i := 0; i < 50; i++ {
+		logger.Info(fmt.Sprintf("message number %d filling up the log file", i))
+	}
+	logger.Flush()
+
+	if len(logger.Backups()) == 0 {
+		t.Fatalf("expected at least one backup after exceeding MaxSize")
+	}
+
+	for _, backup := range logger.Backups() {
+		if _, err := os.Stat(backup); err != nil {
+			t.Fatalf("backup file missing on disk: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(logger.Path()); err != nil {
+		t.Fatalf("primary log file should exist after rotation: %v", err)
+	}
+}
+
+func TestLoggerCompressesBackups(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLoggerWithSuffix("gzip", WithMaxSize(200), WithCompress())
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	for i := 0; i < 50; i++ {
+		logger.Info(fmt.Sprintf("message number %d filling up the log file", i))
+	}
+	logger.Flush()
+
+	allCompressed := func(backups []string) bool {
+		if len(backups) == 0 {
+			return false
+		}
+		for _, b := range backups {
+			if !strings.HasSuffix(b, ".gz") {
+				return false
+			}
+		}
+		return true
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	backups := logger.Backups()
+	for !allCompressed(backups) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		backups = logger.Backups()
+	}
+
+	if len(backups) == 0 {
+		t.Fatalf("expected at least one backup after exceeding MaxSize")
+	}
+	for _, backup := range backups {
+		if !strings.HasSuffix(backup, ".gz") {
+			t.Fatalf("expected compressed backup, got %q", backup)
+		}
+		if _, err := os.Stat(backup); err != nil {
+			t.Fatalf("compressed backup missing on disk: %v", err)
+		}
+	}
+}
+
+func TestLoggerPrunesBackupsBeyondMaxBackups(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLoggerWithSuffix("prune", WithMaxSize(80), WithMaxBackups(2))
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	for i := 0; i < 200; i++ {
+		logger.Info(fmt.Sprintf("padding entry %d to force several rotations", i))
+	}
+	logger.Flush()
+
+	// Pruning runs asynchronously after each rotation; give it a moment.
+	deadline := time.Now().Add(2 * time.Second)
+	for len(logger.Backups()) > 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := len(logger.Backups()); n > 2 {
+		t.Fatalf("expected at most 2 retained backups, got %d", n)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(tempDir, "codex-wrapper-*-prune-*.log"))
+	if err != nil {
+		t.Fatalf("glob error: %v", err)
+	}
+	if len(matches) > 2 {
+		t.Fatalf("expected at most 2 backup files on disk, found %d: %v", len(matches), matches)
+	}
+}
+
+func TestLoggerRotateForcesImmediateRotation(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLoggerWithSuffix("forcerotate")
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("before rotation")
+	logger.Flush()
+
+	if err := logger.Rotate(); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	backups := logger.Backups()
+	if len(backups) != 1 {
+		t.Fatalf("expected exactly one backup after Rotate(), got %d: %v", len(backups), backups)
+	}
+	if _, err := os.Stat(backups[0]); err != nil {
+		t.Fatalf("backup file missing on disk: %v", err)
+	}
+
+	logger.Info("after rotation")
+	logger.Flush()
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "after rotation") {
+		t.Fatalf("expected the reopened primary file to receive new entries, content: %s", data)
+	}
+}
+
+func TestLogRotationOptsFromEnv(t *testing.T) {
+	t.Setenv("CODEX_LOG_MAX_SIZE", "1024")
+	t.Setenv("CODEX_LOG_MAX_AGE", "60")
+	t.Setenv("CODEX_LOG_MAX_BACKUPS", "3")
+	t.Setenv("CODEX_LOG_COMPRESS", "true")
+
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLoggerWithSuffix("envrotate", logRotationOptsFromEnv()...)
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	fs := logger.core.file
+	if fs.maxSize != 1024 {
+		t.Errorf("maxSize = %d, want 1024", fs.maxSize)
+	}
+	if fs.maxAge != 60*time.Second {
+		t.Errorf("maxAge = %v, want 60s", fs.maxAge)
+	}
+	if fs.maxBackups != 3 {
+		t.Errorf("maxBackups = %d, want 3", fs.maxBackups)
+	}
+	if !fs.compress {
+		t.Errorf("compress = false, want true")
+	}
+}
+
+func TestLogRotationOptsFromEnv_UnsetLeavesRotationDisabled(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLoggerWithSuffix("envrotateunset", logRotationOptsFromEnv()...)
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	fs := logger.core.file
+	if fs.maxSize != 0 || fs.maxAge != 0 || fs.maxBackups != 0 || fs.compress {
+		t.Errorf("expected rotation left disabled with no env vars set, got %+v", fs)
+	}
+}
+
+// TestInstallLogRotateHandler_RotatesOnSIGUSR1 asserts SIGUSR1 triggers a
+// rotation via logRotateFn, independently of installDebugDumpHandler which
+// also listens on SIGUSR1.
+func TestInstallLogRotateHandler_RotatesOnSIGUSR1(t *testing.T) {
+	defer resetTestHooks()
+
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLoggerWithSuffix("sigusr1rotate")
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	rotated := make(chan struct{}, 1)
+	logRotateFn = func(l *Logger) error {
+		err := l.Rotate()
+		rotated <- struct{}{}
+		return err
+	}
+
+	stop := installLogRotateHandler(logger)
+	defer stop()
+
+	logger.Info("before signal")
+	logger.Flush()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+
+	select {
+	case <-rotated:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("logRotateFn was not invoked after SIGUSR1")
+	}
+
+	if backups := logger.Backups(); len(backups) != 1 {
+		t.Fatalf("expected exactly one backup after SIGUSR1, got %d: %v", len(backups), backups)
+	}
+}
+
+func TestLoggerStructuredFields(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("request handled", "task_id", "abc123", "duration_ms", 42)
+	logger.Flush()
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+
+	content := string(data)
+	for _, want := range []string{"request handled", "task_id=abc123", "duration_ms=42"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("log file missing %q, content: %s", want, content)
+		}
+	}
+}
+
+func TestLoggerWithStickyFields(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	child := logger.With("task_id", "abc123")
+	child.Warn("retrying", "attempt", 2)
+	logger.Flush()
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+
+	content := string(data)
+	for _, want := range []string{"task_id=abc123", "attempt=2", "WARN: retrying"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("log file missing %q, content: %s", want, content)
+		}
+	}
+}
+
+func TestLoggerJSONFormatter(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLoggerWithSuffix("json", WithFormatter(JSONFormatter))
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	logger.Error("boom", "code", 500)
+	logger.Flush()
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+
+	line := strings.TrimSpace(string(data))
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+		t.Fatalf("expected valid JSON line, got %q: %v", line, err)
+	}
+	if decoded["msg"] != "boom" || decoded["level"] != "ERROR" || decoded["code"] != float64(500) {
+		t.Fatalf("unexpected decoded entry: %+v", decoded)
+	}
+}
+
+func TestLoggerFansOutToAdditionalSink(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	mem := NewMemorySink()
+	logger, err := NewLoggerWithSuffix("fanout", WithSink(mem, TextFormatter, LevelWarn))
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("ignored by memory sink")
+	logger.Error("captured by memory sink")
+	logger.Flush()
+
+	entries := mem.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry in memory sink, got %d: %v", len(entries), entries)
+	}
+	if !strings.Contains(entries[0], "captured by memory sink") {
+		t.Fatalf("unexpected memory sink entry: %q", entries[0])
+	}
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "ignored by memory sink") {
+		t.Fatalf("default file sink should still receive every level")
+	}
+}
+
+func TestLoggerTailReturnsRecentEntries(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLoggerWithSuffix("tail", WithRingBufferSize(3))
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	for i := 0; i < 5; i++ {
+		logger.Info(fmt.Sprintf("entry %d", i))
+	}
+	logger.Flush()
+
+	tail := logger.Tail(10)
+	if len(tail) != 3 {
+		t.Fatalf("expected ring buffer capped at 3, got %d: %v", len(tail), tail)
+	}
+	for i, want := range []string{"entry 2", "entry 3", "entry 4"} {
+		if !strings.Contains(tail[i], want) {
+			t.Fatalf("tail[%d] = %q, want containing %q", i, tail[i], want)
+		}
+	}
+
+	last := logger.Tail(1)
+	if len(last) != 1 || !strings.Contains(last[0], "entry 4") {
+		t.Fatalf("Tail(1) = %v, want last entry only", last)
+	}
+}
+
+func TestLoggerSubscribeReceivesNewEntries(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := logger.Subscribe(ctx)
+
+	logger.Info("subscribed message")
+
+	select {
+	case line := <-ch:
+		if !strings.Contains(line, "subscribed message") {
+			t.Fatalf("unexpected subscription line: %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("subscriber did not receive entry")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatalf("expected channel closed after context cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("subscription channel did not close after cancel")
+	}
+}
+
+func TestLoggerNoRotationWithoutMaxSize(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	for i := 0; i < 50; i++ {
+		logger.Info(fmt.Sprintf("message number %d", i))
+	}
+	logger.Flush()
+
+	if backups := logger.Backups(); len(backups) != 0 {
+		t.Fatalf("expected no backups when rotation is disabled, got %v", backups)
+	}
+}
+
+func TestLogLevelFromEnv(t *testing.T) {
+	cases := []struct {
+		env  string
+		want Level
+	}{
+		{"", LevelInfo},
+		{"debug", LevelDebug},
+		{"DEBUG", LevelDebug},
+		{"warn", LevelWarn},
+		{"error", LevelError},
+		{"info", LevelInfo},
+		{"bogus", LevelInfo},
+	}
+
+	for _, tc := range cases {
+		t.Setenv("CODEX_WRAPPER_LOG", tc.env)
+		if got := logLevelFromEnv(); got != tc.want {
+			t.Errorf("logLevelFromEnv() with CODEX_WRAPPER_LOG=%q = %v, want %v", tc.env, got, tc.want)
+		}
+	}
+}
+
+func TestCodexLogLevelFromEnv(t *testing.T) {
+	cases := []struct {
+		env  string
+		want Level
+	}{
+		{"", LevelDebug},
+		{"bogus", LevelDebug},
+		{"info", LevelInfo},
+		{"warn", LevelWarn},
+		{"error", LevelError},
+	}
+
+	for _, tc := range cases {
+		t.Setenv("CODEX_LOG_LEVEL", tc.env)
+		if got := codexLogLevelFromEnv(); got != tc.want {
+			t.Errorf("codexLogLevelFromEnv() with CODEX_LOG_LEVEL=%q = %v, want %v", tc.env, got, tc.want)
+		}
+	}
+}
+
+func TestLogFormatFromEnv(t *testing.T) {
+	cases := []struct {
+		env  string
+		want Formatter
+	}{
+		{"", TextFormatter},
+		{"bogus", TextFormatter},
+		{"json", JSONFormatter},
+		{"JSON", JSONFormatter},
+		{"logfmt", LogfmtFormatter},
+	}
+
+	for _, tc := range cases {
+		t.Setenv("CODEX_LOG_FORMAT", tc.env)
+		if got := logFormatFromEnv(); got != tc.want {
+			t.Errorf("logFormatFromEnv() with CODEX_LOG_FORMAT=%q = %v, want %v", tc.env, got, tc.want)
+		}
+	}
+}
+
+func TestLoggerNew_AliasesWith(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	child := logger.New("thread_id", "t-1")
+	child.Info("hello")
+	logger.Flush()
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "thread_id=t-1") {
+		t.Fatalf("log file missing thread_id field, content: %s", data)
+	}
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLoggerWithSuffix("logfmt", WithFormatter(LogfmtFormatter))
+	if err != nil {
+		t.Fatalf("NewLoggerWithSuffix() error = %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("hello world", "task_id", "abc 123")
+	logger.Flush()
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+
+	content := string(data)
+	for _, want := range []string{`msg="hello world"`, `level=INFO`, `task_id="abc 123"`} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("logfmt output missing %q, content: %s", want, content)
+		}
+	}
+}
@@ -6,11 +6,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"math"
+	"math/rand"
 	"os"
 	"os/exec"
 	"os/signal"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
@@ -51,6 +55,40 @@ type Config struct {
 	WorkDir       string
 	ExplicitStdin bool
 	Timeout       int
+
+	// Workers, Retries, LogLevel, LogFormat, StreamFormat and Output carry
+	// the first-class --flag values from newWrapperFlagSet. Each defaults
+	// to its CODEX_* env var (see resolveMaxWorkers, resolveMaxRetries,
+	// ...), so callers that still read the env var directly keep working;
+	// applyFlagOverrides re-exports an explicit flag into that env var.
+	Workers      int
+	Retries      int
+	LogLevel     string
+	LogFormat    string
+	StreamFormat string
+	Output       string
+
+	// FailFast and ShutdownGrace are --parallel/--pipeline-only Scheduler
+	// knobs (see Scheduler.WithFailFast/WithShutdownGrace); there is no
+	// CODEX_* env var for them since they're threaded straight from this
+	// Config into the Scheduler rather than read back out of the env.
+	FailFast      bool
+	ShutdownGrace time.Duration
+
+	// ReportPath and MetricsPath are --parallel/--pipeline-only output file
+	// paths (see writeReport/writeMetricsFile in report.go); like FailFast
+	// and ShutdownGrace, there is no CODEX_* env var for either since they
+	// name a file for this one invocation rather than a tunable default.
+	ReportPath  string
+	MetricsPath string
+
+	// StatePath and ResumePath are --parallel/--pipeline-only checkpoint
+	// knobs (see state.go): StatePath is where Run persists a taskStateRecord
+	// snapshot at every layer boundary; ResumePath is a previously-written
+	// state file to resume from (see buildResumePlan). No CODEX_* env var,
+	// for the same per-invocation-file reason as ReportPath/MetricsPath.
+	StatePath  string
+	ResumePath string
 }
 
 // ParallelConfig defines the JSON schema for parallel execution
@@ -60,100 +98,141 @@ type ParallelConfig struct {
 
 // TaskSpec describes an individual task entry in the parallel config
 type TaskSpec struct {
-	ID           string   `json:"id"`
-	Task         string   `json:"task"`
-	WorkDir      string   `json:"workdir,omitempty"`
-	Dependencies []string `json:"dependencies,omitempty"`
-	SessionID    string   `json:"session_id,omitempty"`
-	Mode         string   `json:"-"`
-	UseStdin     bool     `json:"-"`
+	ID              string            `json:"id"`
+	Task            string            `json:"task"`
+	WorkDir         string            `json:"workdir,omitempty"`
+	Dependencies    []string          `json:"dependencies,omitempty"`
+	SessionID       string            `json:"session_id,omitempty"`
+	Retries         int               `json:"retries,omitempty"`
+	RetryBackoff    time.Duration     `json:"retry_backoff,omitempty"`
+	RetryMaxBackoff time.Duration     `json:"retry_max_backoff,omitempty"`
+	RetryMultiplier float64           `json:"retry_backoff_multiplier,omitempty"`
+	RetryJitter     float64           `json:"retry_jitter,omitempty"`
+	RetryOn         []int             `json:"retry_on,omitempty"`
+	RetryOnError    []string          `json:"retry_on_error,omitempty"`
+	Reload          string            `json:"reload,omitempty"`
+	Timeout         int               `json:"timeout,omitempty"`
+	Env             map[string]string `json:"env,omitempty"`
+	Mode            string            `json:"-"`
+	UseStdin        bool              `json:"-"`
+
+	// Finally marks a task produced by a pipeline manifest's "finally:"
+	// section (see parsePipelineYAML): it always runs after the main DAG,
+	// bypassing shouldSkipTask's failed-dependency check.
+	Finally bool `json:"-"`
+
+	// Weight is how many of the worker pool's --workers/CODEX_PARALLEL_WORKERS
+	// slots this task occupies while running (see weightedSemaphore);
+	// unset/zero defaults to 1. A heavier task (e.g. a known
+	// memory/CPU-hungry one) can claim multiple slots so lighter siblings
+	// are throttled around it instead of all starting at once.
+	Weight int `json:"weight,omitempty"`
+}
+
+// AttemptRecord captures the outcome of a single execution attempt of a task
+// that may be retried.
+type AttemptRecord struct {
+	Attempt  int    `json:"attempt"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
 }
 
 // TaskResult captures the execution outcome of a task
 type TaskResult struct {
-	TaskID    string `json:"task_id"`
-	ExitCode  int    `json:"exit_code"`
-	Message   string `json:"message"`
-	SessionID string `json:"session_id"`
-	Error     string `json:"error"`
+	TaskID    string          `json:"task_id"`
+	ExitCode  int             `json:"exit_code"`
+	Message   string          `json:"message"`
+	SessionID string          `json:"session_id"`
+	Error     string          `json:"error"`
+	Attempts  []AttemptRecord `json:"attempts,omitempty"`
+
+	// Resource usage captured from the codex subprocess's cgroup, where
+	// available. Zero when cgroup accounting is unavailable (non-Linux, or
+	// cgroup creation failed).
+	CPUTimeMs       int64 `json:"cpu_time_ms,omitempty"`
+	PeakMemoryBytes int64 `json:"peak_memory_bytes,omitempty"`
+	PeakPIDs        int64 `json:"peak_pids,omitempty"`
+	WallTimeMs      int64 `json:"wall_time_ms,omitempty"`
+
+	// DurationMs is the wall-clock time runTaskWithRetry spent on this task,
+	// across every attempt and backoff sleep. Unlike WallTimeMs (cgroup-only),
+	// it is always populated.
+	DurationMs int64 `json:"duration_ms,omitempty"`
+
+	// StartedAt/EndedAt bracket runTaskWithRetry's attempt loop, across every
+	// attempt and backoff sleep; see --report (report.go), which is the only
+	// reader of these today.
+	StartedAt time.Time `json:"-"`
+	EndedAt   time.Time `json:"-"`
+
+	// Skipped marks a result produced by Scheduler.Run's shouldSkipTask
+	// branch rather than an actual invocation, so --report can tell a
+	// genuinely failed task from one that never ran; see report.go.
+	Skipped bool `json:"-"`
 }
 
-func parseParallelConfig(data []byte) (*ParallelConfig, error) {
-	trimmed := bytes.TrimSpace(data)
-	if len(trimmed) == 0 {
-		return nil, fmt.Errorf("parallel config is empty")
-	}
-
-	tasks := strings.Split(string(trimmed), "---TASK---")
-	var cfg ParallelConfig
-	seen := make(map[string]struct{})
-
-	for _, taskBlock := range tasks {
-		taskBlock = strings.TrimSpace(taskBlock)
-		if taskBlock == "" {
-			continue
-		}
+// TaskSummary is the machine-readable per-task outcome printed by
+// --parallel --format json, so callers can ingest results programmatically
+// instead of scraping the human-readable summary generateFinalOutput prints.
+type TaskSummary struct {
+	TaskID     string `json:"task_id"`
+	Attempts   int    `json:"attempts"`
+	ExitCode   int    `json:"exit_code"`
+	ThreadID   string `json:"thread_id,omitempty"`
+	Message    string `json:"message,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
 
-		parts := strings.SplitN(taskBlock, "---CONTENT---", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("task block missing ---CONTENT--- separator")
+// buildTaskSummaries reduces executeConcurrent's results to the summary
+// schema; a task with no recorded attempts (it never ran, e.g. cancelled or
+// skipped) is reported as a single attempt.
+func buildTaskSummaries(results []TaskResult) []TaskSummary {
+	summaries := make([]TaskSummary, 0, len(results))
+	for _, res := range results {
+		attempts := len(res.Attempts)
+		if attempts == 0 {
+			attempts = 1
 		}
+		summaries = append(summaries, TaskSummary{
+			TaskID:     res.TaskID,
+			Attempts:   attempts,
+			ExitCode:   res.ExitCode,
+			ThreadID:   res.SessionID,
+			Message:    res.Message,
+			DurationMs: res.DurationMs,
+		})
+	}
+	return summaries
+}
 
-		meta := strings.TrimSpace(parts[0])
-		content := strings.TrimSpace(parts[1])
-
-		task := TaskSpec{WorkDir: defaultWorkdir}
-		for _, line := range strings.Split(meta, "\n") {
-			line = strings.TrimSpace(line)
-			if line == "" {
-				continue
-			}
-			kv := strings.SplitN(line, ":", 2)
-			if len(kv) != 2 {
-				continue
-			}
-			key := strings.TrimSpace(kv[0])
-			value := strings.TrimSpace(kv[1])
-
-			switch key {
-			case "id":
-				task.ID = value
-			case "workdir":
-				task.WorkDir = value
-			case "session_id":
-				task.SessionID = value
-				task.Mode = "resume"
-			case "dependencies":
-				for _, dep := range strings.Split(value, ",") {
-					dep = strings.TrimSpace(dep)
-					if dep != "" {
-						task.Dependencies = append(task.Dependencies, dep)
-					}
-				}
-			}
-		}
+// defaultMaxRetries is the number of retries applied when neither
+// TaskSpec.Retries nor CODEX_MAX_RETRIES says otherwise.
+const defaultMaxRetries = 2
 
-		if task.ID == "" {
-			return nil, fmt.Errorf("task missing id field")
-		}
-		if content == "" {
-			return nil, fmt.Errorf("task %q missing content", task.ID)
-		}
-		if _, exists := seen[task.ID]; exists {
-			return nil, fmt.Errorf("duplicate task id: %s", task.ID)
-		}
+// panicExitCode is the sentinel TaskResult.ExitCode recorded when
+// runCodexTaskFn (or a test's failureInjector) panics mid-attempt; the
+// recover happens in runTaskWithRetry's attempt loop, not the outer
+// goroutine, so the panic counts as one attempt and can be retried like any
+// other failure rather than aborting the task with zero recorded attempts.
+const panicExitCode = -2
 
-		task.Task = content
-		cfg.Tasks = append(cfg.Tasks, task)
-		seen[task.ID] = struct{}{}
-	}
+// defaultNonRetryableExitCodes are the exit codes never retried when
+// TaskSpec.RetryOn is unset: timeout, codex-not-found, SIGINT/user-cancel,
+// and a recovered panic. Every other non-zero exit code is retried by
+// default; an explicit RetryOn allow-list (including panicExitCode) can
+// still opt any of these back into retrying.
+var defaultNonRetryableExitCodes = []int{124, 127, 130, panicExitCode}
 
-	if len(cfg.Tasks) == 0 {
-		return nil, fmt.Errorf("no tasks found")
-	}
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+	defaultRetryMultiplier = 2.0
+	defaultRetryJitter     = 0.2
+)
 
-	return &cfg, nil
-}
+// failureInjector lets tests force the outcome of a specific retry attempt
+// without touching runCodexTaskFn. It is nil in production.
+var failureInjector func(attempt int, task TaskSpec) (forced bool, result TaskResult)
 
 func topologicalSort(tasks []TaskSpec) ([][]TaskSpec, error) {
 	idToTask := make(map[string]TaskSpec, len(tasks))
@@ -221,7 +300,47 @@ func topologicalSort(tasks []TaskSpec) ([][]TaskSpec, error) {
 	return layers, nil
 }
 
-var runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+// reloadDAG computes how a freshly re-parsed task list should merge into the
+// tasks that haven't started yet (pending, keyed by ID). A pending task
+// dropped from newTasks comes back in removed, for the caller to cancel. A
+// pending task whose Task body changed in newTasks is rejected - and the
+// whole reload abandoned, per the caller's contract - unless the new spec
+// opts in with Reload == "replace". Tasks already completed are excluded
+// from merged even if still present in newTasks, since they're immutable
+// history by the time a reload observes them.
+func reloadDAG(pending map[string]TaskSpec, completed map[string]TaskResult, newTasks []TaskSpec) (merged []TaskSpec, removed []string, err error) {
+	newByID := make(map[string]TaskSpec, len(newTasks))
+	for _, t := range newTasks {
+		newByID[t.ID] = t
+	}
+
+	for id, old := range pending {
+		nt, ok := newByID[id]
+		if !ok {
+			removed = append(removed, id)
+			continue
+		}
+		if nt.Task != old.Task && nt.Reload != "replace" {
+			return nil, nil, fmt.Errorf("task %q body changed; set reload: replace to allow it", id)
+		}
+		merged = append(merged, nt)
+	}
+
+	for _, nt := range newTasks {
+		if _, stillPending := pending[nt.ID]; stillPending {
+			continue
+		}
+		if _, done := completed[nt.ID]; done {
+			continue
+		}
+		merged = append(merged, nt)
+	}
+
+	sort.Strings(removed)
+	return merged, removed, nil
+}
+
+var runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 	if task.WorkDir == "" {
 		task.WorkDir = defaultWorkdir
 	}
@@ -232,60 +351,671 @@ var runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
 		task.UseStdin = true
 	}
 
-	return runCodexTask(task, true, timeout)
+	return runCodexTaskWithContext(ctx, task, nil, false, true, timeout)
+}
+
+// shutdownGracePeriod bounds how long executeConcurrent waits, once ctx is
+// cancelled, for already-launched tasks to observe the cancellation and
+// return before the rest of the current layer is reported as cancelled
+// outright. Matches the logger's close timeout.
+const shutdownGracePeriod = 5 * time.Second
+
+// cancelledResult reports task as cancelled, using the same exit code a
+// Ctrl-C/SIGTERM would produce for the wrapper itself.
+func cancelledResult(task TaskSpec) TaskResult {
+	return TaskResult{TaskID: task.ID, ExitCode: 130, Error: "cancelled"}
+}
+
+// failFastExitCode marks a task cancelled by --fail-fast (a sibling's
+// failure within its own layer), distinct from cancelledResult's 130 so
+// callers can tell "the run was interrupted" from "fail-fast gave up on
+// this task early" - parent, the layer's own context without the outer
+// one, tells layerCancelledResult which of the two just happened.
+const failFastExitCode = -1
+
+// layerCancelledResult reports task as cancelled by its layer's context,
+// attributing it to the outer ctx (a real shutdown) when that's already
+// done, or to --fail-fast otherwise.
+func layerCancelledResult(task TaskSpec, parent context.Context) TaskResult {
+	if parent.Err() != nil {
+		return cancelledResult(task)
+	}
+	return TaskResult{TaskID: task.ID, ExitCode: failFastExitCode, Error: "cancelled"}
+}
+
+// TaskEvent reports a task's state transition as a Scheduler runs a DAG, or
+// (for TaskRunning with a PID, and the two chunk states) progress within a
+// single task's execution. The daemon (see daemon.go) streams these to
+// attached clients, --parallel --format ndjson prints them as they occur,
+// and executeConcurrent callers that only need the final results can ignore
+// them entirely. Field names are the stable wire schema: a reader should be
+// able to key off "type" without caring which of the optional fields apply.
+type TaskEvent struct {
+	TaskID    string      `json:"task_id"`
+	DAGID     string      `json:"dag_id,omitempty"`
+	State     string      `json:"type"`
+	Result    *TaskResult `json:"result,omitempty"`
+	PID       int         `json:"pid,omitempty"`
+	StartedAt *time.Time  `json:"started_at,omitempty"`
+	Chunk     string      `json:"chunk,omitempty"`
+	Timestamp time.Time   `json:"ts"`
+}
+
+// Task states emitted on a Scheduler's event channel, or via taskEventSink
+// from within a single task's execution.
+const (
+	TaskReceived    = "received"
+	TaskQueued      = "queued"
+	TaskRunning     = "running"
+	TaskSucceeded   = "succeeded"
+	TaskFailed      = "failed"
+	TaskSkipped     = "skipped"
+	TaskStdoutChunk = "stdout_chunk"
+	TaskStderrChunk = "stderr_chunk"
+)
+
+// TaskStateUpdater receives every TaskEvent a running task produces, modeled
+// on Nomad's AllocStateUpdater callback: a single hook that downstream code
+// (NDJSON output, the daemon's dagRun, eventually others) can plug into
+// instead of the wrapper hard-coding its own idea of where progress goes.
+type TaskStateUpdater func(TaskEvent)
+
+// taskEventSink, when non-nil, receives the running/stdout_chunk/stderr_chunk
+// events runCodexTaskExec emits from inside a single task's execution — the
+// "received"/"queued"/"succeeded"/"failed"/"skipped" transitions around it
+// come from a Scheduler's own events channel instead. nil is always a valid,
+// inert no-op, exactly like a nil TaskStateUpdater value.
+//
+// It is process-global, so it's only safe to set for the lifetime of one
+// DAG run per process — the --parallel --format ndjson path (see
+// runParallelNDJSON in ndjson.go) is the only current caller. Something that
+// runs more than one DAG per process concurrently, like the daemon, must not
+// wire this up without giving it per-run scoping first.
+var taskEventSink TaskStateUpdater
+
+func emitTaskEvent(sink TaskStateUpdater, ev TaskEvent) {
+	if sink == nil {
+		return
+	}
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+	sink(ev)
+}
+
+// chunkEventWriter splits a process's raw stdout/stderr stream into lines
+// and emits each as a TaskEvent on sink, the same line-buffering logWriter
+// does for human-readable logging.
+type chunkEventWriter struct {
+	taskID string
+	state  string
+	sink   TaskStateUpdater
+	buf    []byte
+}
+
+func (w *chunkEventWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		line := string(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+		emitTaskEvent(w.sink, TaskEvent{TaskID: w.taskID, State: w.state, Chunk: line})
+	}
+	return len(p), nil
+}
+
+// weightedSemaphore is a counting semaphore whose Acquire can claim more
+// than one slot at once (see TaskSpec.Weight) - something a plain
+// chan struct{} semaphore can't express without either handing out
+// multiple tokens non-atomically (which can deadlock: two weight-2 tasks
+// each holding one of the last two tokens, both waiting on a third) or
+// serializing every acquire behind a single lock for the whole pool, which
+// is exactly what this does instead, bounded and with ctx cancellation.
+type weightedSemaphore struct {
+	mu      sync.Mutex
+	cap     int
+	used    int
+	waiters []chan struct{}
+}
+
+func newWeightedSemaphore(capacity int) *weightedSemaphore {
+	return &weightedSemaphore{cap: capacity}
+}
+
+// Acquire blocks until weight slots are free, or ctx is done (returning
+// ctx.Err()). weight is capped at the pool's total capacity so a single
+// over-weight task can never deadlock the rest of the pool out forever.
+func (w *weightedSemaphore) Acquire(ctx context.Context, weight int) error {
+	if weight > w.cap {
+		weight = w.cap
+	}
+	if weight < 1 {
+		weight = 1
+	}
+	for {
+		w.mu.Lock()
+		if w.used+weight <= w.cap {
+			w.used += weight
+			w.mu.Unlock()
+			return nil
+		}
+		wake := make(chan struct{})
+		w.waiters = append(w.waiters, wake)
+		w.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release frees weight slots and wakes every pending Acquire to recheck;
+// the one whose request now fits makes progress, the rest re-block.
+func (w *weightedSemaphore) Release(weight int) {
+	w.mu.Lock()
+	w.used -= weight
+	waiters := w.waiters
+	w.waiters = nil
+	w.mu.Unlock()
+	for _, wake := range waiters {
+		close(wake)
+	}
+}
+
+// Scheduler runs a topologically-sorted task DAG layer by layer, honoring
+// ctx cancellation exactly as executeConcurrent always has. Its events
+// channel, when set, additionally receives a TaskEvent at every state
+// transition — the DAG registry in daemon.go uses this to stream progress to
+// attached clients.
+type Scheduler struct {
+	layers  [][]TaskSpec
+	timeout int
+	events  chan<- TaskEvent
+
+	// workers bounds how many tasks run concurrently across the whole DAG,
+	// not just within a layer; a layer wider than workers queues the rest.
+	// Its capacity is slots, not tasks - see TaskSpec.Weight and
+	// weightedSemaphore.
+	workers *weightedSemaphore
+
+	// reloadCh carries a SIGHUP-triggered re-parse of the task config;
+	// RequestReload keeps only the latest pending reload. warnFn reports a
+	// rejected or cyclic reload without touching the in-flight plan.
+	reloadCh chan []TaskSpec
+	warnFn   func(string)
+
+	// failFast, when set via WithFailFast, cancels the rest of the current
+	// layer as soon as one of its tasks fails, instead of waiting out the
+	// whole layer. shutdownGrace bounds how long Run waits for in-flight
+	// tasks to notice a cancellation (outer ctx or fail-fast) before
+	// force-returning; see WithShutdownGrace.
+	failFast      bool
+	shutdownGrace time.Duration
+
+	// preCompleted holds --resume's carried-over results: tasks whose prior
+	// run already succeeded with a matching spec hash (see buildResumePlan
+	// in state.go). Run injects these in place of actually invoking the
+	// task, so a resumed run's results still come out in the normal
+	// layer-then-launch-order - pre- and post-resume tasks interleave
+	// exactly as buildPipelineLayers/topologicalSort laid them out.
+	preCompleted map[string]TaskResult
+
+	// statePath, when set via WithStatePath, makes Run checkpoint a
+	// taskStateRecord snapshot to this path at every layer boundary (see
+	// persistState in state.go) - "running" for the layer about to launch,
+	// then its real terminal status once that layer drains. A layer
+	// boundary, not each individual task, is the checkpoint granularity
+	// Run's layer-barrier design can offer without restructuring it.
+	statePath string
+}
+
+func newScheduler(layers [][]TaskSpec, timeout int) *Scheduler {
+	return &Scheduler{
+		layers:        layers,
+		timeout:       timeout,
+		workers:       newWeightedSemaphore(resolveMaxWorkers()),
+		reloadCh:      make(chan []TaskSpec, 1),
+		warnFn:        logWarn,
+		shutdownGrace: shutdownGracePeriod,
+	}
+}
+
+// WithFailFast enables or disables cancelling a layer's remaining tasks as
+// soon as one of them fails (see the failFast field).
+func (s *Scheduler) WithFailFast(failFast bool) *Scheduler {
+	s.failFast = failFast
+	return s
+}
+
+// WithShutdownGrace overrides the default shutdownGracePeriod a non-positive
+// d leaves the default in place, so callers can pass a zero-value Config
+// field without disabling the grace period entirely.
+func (s *Scheduler) WithShutdownGrace(d time.Duration) *Scheduler {
+	if d > 0 {
+		s.shutdownGrace = d
+	}
+	return s
+}
+
+// WithPreCompleted seeds Run with results already known from a prior
+// --resume run (see buildResumePlan); Run reports these instead of
+// launching the matching task.
+func (s *Scheduler) WithPreCompleted(pre map[string]TaskResult) *Scheduler {
+	s.preCompleted = pre
+	return s
+}
+
+// WithStatePath enables --state checkpointing to path at every layer
+// boundary (see the statePath field).
+func (s *Scheduler) WithStatePath(path string) *Scheduler {
+	s.statePath = path
+	return s
+}
+
+// RequestReload enqueues newTasks for Run to merge in at the next layer
+// boundary, replacing any not-yet-applied reload already pending.
+func (s *Scheduler) RequestReload(newTasks []TaskSpec) {
+	select {
+	case <-s.reloadCh:
+	default:
+	}
+	s.reloadCh <- newTasks
+}
+
+func (s *Scheduler) emit(taskID, state string, result *TaskResult) {
+	exportStore.RecordTransition(taskID, state)
+	if s.events == nil {
+		return
+	}
+	ev := TaskEvent{TaskID: taskID, State: state, Result: result, Timestamp: time.Now()}
+	select {
+	case s.events <- ev:
+	default:
+	}
 }
 
-func executeConcurrent(layers [][]TaskSpec, timeout int) []TaskResult {
+// Run executes the DAG and returns every task's final result, in the same
+// layer-then-launch-order as executeConcurrent always has.
+func (s *Scheduler) Run(ctx context.Context) []TaskResult {
+	layers := s.layers
+	timeout := s.timeout
+
 	totalTasks := 0
+	known := make(map[string]TaskSpec)
 	for _, layer := range layers {
 		totalTasks += len(layer)
+		for _, t := range layer {
+			known[t.ID] = t
+		}
 	}
 
 	results := make([]TaskResult, 0, totalTasks)
 	failed := make(map[string]TaskResult, totalTasks)
 	resultsCh := make(chan TaskResult, totalTasks)
 
-	for _, layer := range layers {
+	reportCancelled := func(tasks []TaskSpec) {
+		for _, task := range tasks {
+			res := cancelledResult(task)
+			results = append(results, res)
+			failed[task.ID] = res
+			s.emit(task.ID, TaskFailed, &res)
+		}
+	}
+
+	// applyReload merges a SIGHUP-triggered re-parse into the plan at the
+	// current layer boundary: tasks already completed keep their recorded
+	// result (and, via known, their original spec so topologicalSort can
+	// still resolve edges onto them); pending tasks missing from newTasks
+	// are cancelled; a rejected or cyclic merge leaves layers untouched.
+	applyReload := func(layerIdx int, newTasks []TaskSpec) {
+		completed := make(map[string]TaskResult, len(results))
+		for _, r := range results {
+			completed[r.TaskID] = r
+		}
+
+		pending := make(map[string]TaskSpec)
+		for _, l := range layers[layerIdx:] {
+			for _, t := range l {
+				pending[t.ID] = t
+			}
+		}
+
+		merged, removed, err := reloadDAG(pending, completed, newTasks)
+		if err != nil {
+			s.warnFn(fmt.Sprintf("SIGHUP reload rejected, keeping existing plan: %v", err))
+			return
+		}
+
+		full := make([]TaskSpec, 0, len(known)+len(merged))
+		for id, t := range known {
+			if _, done := completed[id]; done {
+				full = append(full, t)
+			}
+		}
+		full = append(full, merged...)
+
+		newLayers, err := topologicalSort(full)
+		if err != nil {
+			s.warnFn(fmt.Sprintf("SIGHUP reload produces a cyclic DAG, keeping existing plan: %v", err))
+			return
+		}
+
+		replanned := make([][]TaskSpec, 0, len(newLayers))
+		for _, l := range newLayers {
+			keep := make([]TaskSpec, 0, len(l))
+			for _, t := range l {
+				if _, done := completed[t.ID]; !done {
+					keep = append(keep, t)
+				}
+			}
+			if len(keep) > 0 {
+				replanned = append(replanned, keep)
+			}
+		}
+
+		layers = append(append([][]TaskSpec{}, layers[:layerIdx]...), replanned...)
+		for _, t := range merged {
+			known[t.ID] = t
+		}
+		if len(removed) > 0 {
+			cancelled := make([]TaskSpec, 0, len(removed))
+			for _, id := range removed {
+				cancelled = append(cancelled, pending[id])
+			}
+			reportCancelled(cancelled)
+		}
+	}
+
+	for layerIdx := 0; ; layerIdx++ {
+		if ctx.Err() != nil {
+			for _, remaining := range layers[layerIdx:] {
+				reportCancelled(remaining)
+			}
+			return results
+		}
+
+		// Checked even once layerIdx reaches the last known layer, so a
+		// reload requested while that layer was still running - with no
+		// further layer already queued to observe it - still gets a chance
+		// to extend layers and keep this loop going.
+		select {
+		case newTasks := <-s.reloadCh:
+			applyReload(layerIdx, newTasks)
+		default:
+		}
+
+		if layerIdx >= len(layers) {
+			return results
+		}
+
+		layer := layers[layerIdx]
+		metricsRegistry.LayerDepth.Set(int64(layerIdx + 1))
+		layerStart := time.Now()
+
 		var wg sync.WaitGroup
-		executed := 0
+		launched := make([]TaskSpec, 0, len(layer))
+
+		// layerCtx lets --fail-fast cancel this layer's remaining tasks
+		// without tearing down layers after it; layerCancel is always
+		// called below so it's never left pending past this layer.
+		layerCtx, layerCancel := context.WithCancel(ctx)
 
 		for _, task := range layer {
+			if pre, ok := s.preCompleted[task.ID]; ok {
+				results = append(results, pre)
+				if pre.ExitCode != 0 || pre.Error != "" {
+					failed[task.ID] = pre
+					s.emit(task.ID, TaskFailed, &pre)
+				} else {
+					s.emit(task.ID, TaskSucceeded, &pre)
+				}
+				continue
+			}
+
 			if skip, reason := shouldSkipTask(task, failed); skip {
-				res := TaskResult{TaskID: task.ID, ExitCode: 1, Error: reason}
+				metricsRegistry.TasksSkipped.Inc("failed_dependency")
+				res := TaskResult{TaskID: task.ID, ExitCode: 1, Error: reason, Skipped: true}
 				results = append(results, res)
 				failed[task.ID] = res
+				s.emit(task.ID, TaskSkipped, &res)
 				continue
 			}
 
-			executed++
+			launched = append(launched, task)
+			s.emit(task.ID, TaskQueued, nil)
 			wg.Add(1)
 			go func(ts TaskSpec) {
 				defer wg.Done()
+				weight := ts.Weight
+				if weight < 1 {
+					weight = 1
+				}
+				if err := s.workers.Acquire(layerCtx, weight); err != nil {
+					resultsCh <- layerCancelledResult(ts, ctx)
+					return
+				}
+				defer s.workers.Release(weight)
 				defer func() {
 					if r := recover(); r != nil {
 						resultsCh <- TaskResult{TaskID: ts.ID, ExitCode: 1, Error: fmt.Sprintf("panic: %v", r)}
 					}
 				}()
-				resultsCh <- runCodexTaskFn(ts, timeout)
+				s.emit(ts.ID, TaskRunning, nil)
+				res := runTaskWithRetry(layerCtx, ts, timeout)
+				resultsCh <- res
+				if s.failFast && ctx.Err() == nil && (res.ExitCode != 0 || res.Error != "") {
+					layerCancel()
+				}
 			}(task)
 		}
 
-		wg.Wait()
+		s.persistState(layerIdx, layers, results, launched)
 
-		for i := 0; i < executed; i++ {
-			res := <-resultsCh
+		waitDone := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(waitDone)
+		}()
+
+		select {
+		case <-waitDone:
+		case <-layerCtx.Done():
+			select {
+			case <-waitDone:
+			case <-time.After(s.shutdownGrace):
+			}
+		}
+		layerCancel()
+
+		collected := make(map[string]TaskResult, len(launched))
+	drain:
+		for range launched {
+			select {
+			case res := <-resultsCh:
+				collected[res.TaskID] = res
+			default:
+				break drain
+			}
+		}
+
+		for _, task := range launched {
+			res, ok := collected[task.ID]
+			if !ok {
+				res = layerCancelledResult(task, ctx)
+			}
 			results = append(results, res)
 			if res.ExitCode != 0 || res.Error != "" {
-				failed[res.TaskID] = res
+				failed[task.ID] = res
+				s.emit(task.ID, TaskFailed, &res)
+			} else {
+				s.emit(task.ID, TaskSucceeded, &res)
+			}
+		}
+
+		exportStore.RecordLayer(layerIdx, time.Since(layerStart))
+		s.persistState(layerIdx, layers, results, nil)
+
+		if ctx.Err() != nil {
+			for _, remaining := range layers[layerIdx+1:] {
+				reportCancelled(remaining)
 			}
+			return results
 		}
 	}
+}
 
-	return results
+func executeConcurrent(ctx context.Context, layers [][]TaskSpec, timeout int) []TaskResult {
+	return newScheduler(layers, timeout).Run(ctx)
+}
+
+// safeInvokeTask runs one attempt via runCodexTaskFn (or failureInjector, in
+// tests) and recovers a panic from either into a TaskResult carrying
+// panicExitCode, so a single bad attempt doesn't abort runTaskWithRetry's
+// whole retry loop with zero attempts recorded.
+func safeInvokeTask(ctx context.Context, task TaskSpec, timeoutSec, attempt int) (result TaskResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = TaskResult{TaskID: task.ID, ExitCode: panicExitCode, Error: fmt.Sprintf("panic: %v", r)}
+		}
+	}()
+
+	if failureInjector != nil {
+		if forced, injected := failureInjector(attempt, task); forced {
+			return injected
+		}
+	}
+	return runCodexTaskFn(ctx, task, timeoutSec)
+}
+
+// runTaskWithRetry runs task via runCodexTaskFn (or failureInjector, in
+// tests), retrying retryable failures with exponential backoff and ±20%
+// jitter until task.Retries is exhausted, the timeout deadline (an absolute
+// bound across all attempts) would be exceeded, or ctx is cancelled. Every
+// attempt is recorded on the returned TaskResult's Attempts field.
+func runTaskWithRetry(ctx context.Context, task TaskSpec, timeoutSec int) TaskResult {
+	start := time.Now()
+
+	if task.Timeout > 0 {
+		timeoutSec = task.Timeout
+	}
+
+	backoff := task.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	maxBackoff := task.RetryMaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+	multiplier := task.RetryMultiplier
+	if multiplier <= 0 {
+		multiplier = defaultRetryMultiplier
+	}
+	jitter := task.RetryJitter
+	if jitter <= 0 {
+		jitter = defaultRetryJitter
+	}
+	maxRetries := task.Retries
+	if maxRetries <= 0 {
+		maxRetries = resolveMaxRetries()
+	}
+
+	deadline := time.Now().Add(time.Duration(timeoutSec) * time.Second)
+
+	var attempts []AttemptRecord
+	var result TaskResult
+
+retryLoop:
+	for attempt := 0; ; attempt++ {
+		result = safeInvokeTask(ctx, task, timeoutSec, attempt)
+
+		attempts = append(attempts, AttemptRecord{Attempt: attempt, ExitCode: result.ExitCode, Error: result.Error})
+
+		if result.ExitCode == 0 && result.Error == "" {
+			break
+		}
+		retryable := isRetryableExitCode(result.ExitCode, task.RetryOn) || matchesRetryOnError(result.Error, task.RetryOnError)
+		if ctx.Err() != nil || attempt >= maxRetries || !retryable {
+			break
+		}
+
+		sleep := retryBackoffWithJitter(backoff, maxBackoff, attempt, multiplier, jitter)
+		if time.Now().Add(sleep).After(deadline) {
+			break
+		}
+		select {
+		case <-time.After(sleep):
+		case <-ctx.Done():
+			break retryLoop
+		}
+	}
+
+	result.Attempts = attempts
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.StartedAt = start
+	result.EndedAt = start.Add(time.Duration(result.DurationMs) * time.Millisecond)
+	return result
+}
+
+// isRetryableExitCode reports whether code should be retried. When retryOn
+// is explicit (TaskSpec.RetryOn), it's an allow-list: only those codes
+// retry. Left unset, every non-zero code retries except
+// defaultNonRetryableExitCodes.
+func isRetryableExitCode(code int, retryOn []int) bool {
+	if retryOn != nil {
+		for _, c := range retryOn {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+	if code == 0 {
+		return false
+	}
+	for _, c := range defaultNonRetryableExitCodes {
+		if c == code {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesRetryOnError reports whether errMsg contains any of substrs
+// (case-insensitive), letting a task opt additional failures (e.g. "timeout",
+// "stdin pipe") into retrying even when their exit code isn't retryable on
+// its own - the two checks are ORed together in runTaskWithRetry.
+func matchesRetryOnError(errMsg string, substrs []string) bool {
+	if errMsg == "" {
+		return false
+	}
+	lower := strings.ToLower(errMsg)
+	for _, s := range substrs {
+		if s != "" && strings.Contains(lower, strings.ToLower(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryBackoffWithJitter returns min(base*multiplier^attempt, max) with
+// jitter of ±jitterFrac applied uniformly at random.
+func retryBackoffWithJitter(base, max time.Duration, attempt int, multiplier, jitterFrac float64) time.Duration {
+	d := time.Duration(float64(base) * math.Pow(multiplier, float64(attempt)))
+	if max > 0 && d > max {
+		d = max
+	}
+	jitter := 1 + (rand.Float64()*2*jitterFrac - jitterFrac)
+	return time.Duration(float64(d) * jitter)
 }
 
 func shouldSkipTask(task TaskSpec, failed map[string]TaskResult) (bool, string) {
-	if len(task.Dependencies) == 0 {
+	if task.Finally || len(task.Dependencies) == 0 {
 		return false, ""
 	}
 
@@ -303,6 +1033,28 @@ func shouldSkipTask(task TaskSpec, failed map[string]TaskResult) (bool, string)
 	return true, fmt.Sprintf("skipped due to failed dependencies: %s", strings.Join(blocked, ","))
 }
 
+// parallelExitCode derives --parallel's process exit code from per-task
+// results: cancellation (130) takes priority over any other non-zero exit
+// code, since a caller who hit Ctrl-C doesn't need to know which task was
+// also failing on its own.
+func parallelExitCode(results []TaskResult) int {
+	code := 0
+	cancelled := false
+	for _, res := range results {
+		if res.ExitCode == 130 && res.Error == "cancelled" {
+			cancelled = true
+			continue
+		}
+		if res.ExitCode != 0 {
+			code = res.ExitCode
+		}
+	}
+	if cancelled {
+		return 130
+	}
+	return code
+}
+
 func generateFinalOutput(results []TaskResult) string {
 	var sb strings.Builder
 
@@ -331,6 +1083,13 @@ func generateFinalOutput(results []TaskResult) string {
 		if res.SessionID != "" {
 			sb.WriteString(fmt.Sprintf("Session: %s\n", res.SessionID))
 		}
+		if len(res.Attempts) > 1 {
+			sb.WriteString(fmt.Sprintf("Attempts: %d\n", len(res.Attempts)))
+		}
+		if res.CPUTimeMs != 0 || res.PeakMemoryBytes != 0 || res.PeakPIDs != 0 || res.WallTimeMs != 0 {
+			sb.WriteString(fmt.Sprintf("Metrics: cpu=%dms peak_mem=%dB peak_pids=%d wall=%dms\n",
+				res.CPUTimeMs, res.PeakMemoryBytes, res.PeakPIDs, res.WallTimeMs))
+		}
 		if res.Message != "" {
 			sb.WriteString(fmt.Sprintf("\n%s\n", res.Message))
 		}
@@ -360,6 +1119,11 @@ func main() {
 
 // run is the main logic, returns exit code for testability
 func run() (exitCode int) {
+	// Registered before the --version/--help early returns below so the
+	// cleanup hook runs for every invocation, not just ones that reach the
+	// logger/metrics setup further down.
+	defer runCleanupHook()
+
 	// Handle --version and --help first (no logger needed)
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -372,14 +1136,69 @@ func run() (exitCode int) {
 		}
 	}
 
-	// Initialize logger for all other commands
-	logger, err := NewLogger()
+	// Initialize logger for all other commands. The file sink always keeps
+	// everything for post-hoc debugging; an interactive single-task run also
+	// mirrors entries to stderr so a human watching the terminal sees
+	// progress, gated to CODEX_WRAPPER_LOG's level (debug|info|warn|error,
+	// default info). --daemon/--remote/--parallel stay file-only so their
+	// machine-readable stdout isn't interleaved with log lines.
+	logOpts := append(stderrSinkOpts(isInteractiveInvocation(os.Args)), logRotationOptsFromEnv()...)
+	logger, err := NewLogger(logOpts...)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: failed to initialize logger: %v\n", err)
 		return 1
 	}
 	setLogger(logger)
 
+	// SIGUSR1 (or the hidden --debug-dump flag, for a one-shot capture with
+	// no process to signal) writes a goroutine/heap/cpu/trace bundle next
+	// to the log file, for diagnosing a stuck wrapper without an HTTP pprof
+	// endpoint. The same signal also forces a log rotation/reopen, so an
+	// external log shipper (logrotate, Vector) can manage the file safely.
+	stopDebugDump := installDebugDumpHandler(logger)
+	defer stopDebugDump()
+	stopLogRotate := installLogRotateHandler(logger)
+	defer stopLogRotate()
+	if hasFlag(os.Args[1:], debugDumpFlag) {
+		dumpDebugBundle(logger)
+	}
+
+	// --chaos/CODEX_WRAPPER_CHAOS injects controlled faults (delay,
+	// stdout-throttle, kill-after, drop-lines, fail-rate) into every task
+	// this process runs, for exercising the timeout/cancellation/retry
+	// paths under test. Requires --i-know-this-is-dangerous so it can't be
+	// enabled by a stray environment variable alone.
+	if spec := resolveChaosSpec(os.Args[1:]); spec != "" {
+		if !hasFlag(os.Args[1:], chaosDangerFlag) {
+			fmt.Fprintf(os.Stderr, "ERROR: --chaos (or CODEX_WRAPPER_CHAOS) requires %s to confirm fault injection\n", chaosDangerFlag)
+			return 1
+		}
+		monkeys, err := parseChaosSpec(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		chaosMonkeys = monkeys
+		logWarn(fmt.Sprintf("chaos mode enabled: %s", spec))
+	}
+
+	metricsSrv := maybeStartMetricsServer(os.Args[1:])
+	pushCtx, pushCancel := context.WithCancel(context.Background())
+	pushDone := maybeStartMetricsPusher(pushCtx, os.Args[1:])
+	exporter, exportDone := maybeStartExporter(pushCtx, os.Args[1:])
+	defer func() {
+		pushCancel()
+		if pushDone != nil {
+			<-pushDone
+		}
+		if exporter != nil {
+			<-exportDone
+		}
+		if metricsSrv != nil {
+			metricsSrv.Shutdown(context.Background())
+		}
+	}()
+
 	defer func() {
 		logger := activeLogger()
 		if logger != nil {
@@ -388,8 +1207,12 @@ func run() (exitCode int) {
 		if err := closeLogger(); err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: failed to close logger: %v\n", err)
 		}
-		// Always remove log file after completion
-		if logger != nil {
+		// Remove the log file after an ordinary completion, but keep it
+		// around for debugging when the run was cut short by a signal (the
+		// single-task path's ctx.Err() branch in runCodexTaskExec always
+		// reports that as exitCode 130, regardless of which of
+		// SIGINT/SIGTERM/SIGHUP fired).
+		if logger != nil && exitCode != 130 {
 			if err := logger.RemoveLogFile(); err != nil && !os.IsNotExist(err) {
 				// Silently ignore removal errors
 			}
@@ -400,13 +1223,18 @@ func run() (exitCode int) {
 	// Handle remaining commands
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
-		case "--parallel":
-			if len(os.Args) > 2 {
-				fmt.Fprintln(os.Stderr, "ERROR: --parallel reads its task configuration from stdin and does not accept additional arguments.")
-				fmt.Fprintln(os.Stderr, "Usage examples:")
-				fmt.Fprintln(os.Stderr, "  codex-wrapper --parallel < tasks.txt")
-				fmt.Fprintln(os.Stderr, "  echo '...' | codex-wrapper --parallel")
-				fmt.Fprintln(os.Stderr, "  codex-wrapper --parallel <<'EOF'")
+		case "--daemon":
+			listenAddr := ""
+			if len(os.Args) > 3 && os.Args[2] == "--listen" {
+				listenAddr = os.Args[3]
+			} else if len(os.Args) > 2 {
+				fmt.Fprintln(os.Stderr, "ERROR: usage: codex-wrapper --daemon [--listen unix:///path|tcp://host:port]")
+				return 1
+			}
+			return runDaemon(listenAddr)
+		case "--remote":
+			if len(os.Args) != 4 || os.Args[3] != "--parallel" {
+				fmt.Fprintln(os.Stderr, "ERROR: usage: codex-wrapper --remote <unix://...|tcp://...> --parallel")
 				return 1
 			}
 			data, err := io.ReadAll(stdinReader)
@@ -414,31 +1242,217 @@ func run() (exitCode int) {
 				fmt.Fprintf(os.Stderr, "ERROR: failed to read stdin: %v\n", err)
 				return 1
 			}
+			return runRemoteParallel(os.Args[2], data)
+		case "--parallel":
+			extraArgs := os.Args[2:]
+			if coordinatorAddr, ok := flagValue(extraArgs, "--coordinator"); ok {
+				dagID, _ := flagValue(extraArgs, "--dag")
+				if dagID == "" {
+					fmt.Fprintln(os.Stderr, "ERROR: --coordinator requires --dag <dag-id>")
+					return 1
+				}
+				coord, err := coordinatorFromURL(coordinatorAddr)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+					return 1
+				}
+
+				runCtx, shutdownExitCode, stopSignals := newShutdownContext(context.Background())
+				defer stopSignals()
+
+				if hasFlag(extraArgs, "--worker") {
+					workerID := fmt.Sprintf("%s-%d", getEnv("HOSTNAME", "worker"), os.Getpid())
+					code := runCoordinatedWorker(runCtx, coord, dagID, workerID, resolveTimeout())
+					awaitGracefulShutdown(runCtx)
+					if sc := shutdownExitCode(); sc != 0 {
+						return sc
+					}
+					return code
+				}
+
+				data, err := io.ReadAll(stdinReader)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: failed to read stdin: %v\n", err)
+					return 1
+				}
+				cfg, err := parseParallelConfig(data)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+					return 1
+				}
+				code := runCoordinatedSubmitter(runCtx, coord, dagID, cfg)
+				awaitGracefulShutdown(runCtx)
+				if sc := shutdownExitCode(); sc != 0 {
+					return sc
+				}
+				return code
+			}
 
+			flagSet, flagCfg := newWrapperFlagSet("codex-wrapper --parallel")
+			format := flagSet.String("format", "", "output framing: ndjson|json (default: human-readable summary)")
+			configPath := flagSet.String("config", "", "read the task config from this file instead of stdin; required for SIGHUP to live-reload it")
+			flagSet.SetOutput(io.Discard)
+			if err := flagSet.Parse(extraArgs); err != nil || flagSet.NArg() != 0 {
+				fmt.Fprintln(os.Stderr, "ERROR: --parallel reads its task configuration from stdin; unsupported arguments.")
+				fmt.Fprintln(os.Stderr, "Usage examples:")
+				fmt.Fprintln(os.Stderr, "  codex-wrapper --parallel < tasks.txt")
+				fmt.Fprintln(os.Stderr, "  codex-wrapper --parallel --format ndjson < tasks.txt")
+				fmt.Fprintln(os.Stderr, "  codex-wrapper --parallel --workers 4 --retries 1 < tasks.txt")
+				fmt.Fprintln(os.Stderr, "  codex-wrapper --parallel --config tasks.txt   Reload on SIGHUP")
+				return 1
+			}
+			if *format != "" && *format != "json" && *format != "ndjson" {
+				fmt.Fprintf(os.Stderr, "ERROR: unsupported --format %q (expected json or ndjson)\n", *format)
+				return 1
+			}
+			applyFlagOverrides(flagSet, flagCfg)
+
+			var data []byte
+			var err error
+			if *configPath != "" {
+				data, err = os.ReadFile(*configPath)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: failed to read %s: %v\n", *configPath, err)
+					return 1
+				}
+			} else {
+				data, err = io.ReadAll(stdinReader)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: failed to read stdin: %v\n", err)
+					return 1
+				}
+			}
 			cfg, err := parseParallelConfig(data)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 				return 1
 			}
 
-			timeoutSec := resolveTimeout()
+			// A config file hands SIGHUP to installConfigReloadHandler instead
+			// of treating it as a shutdown signal, so a live reload never
+			// races a SIGHUP-triggered teardown of the same run.
+			shutdownSignals := []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+			if *configPath == "" {
+				shutdownSignals = append(shutdownSignals, syscall.SIGHUP)
+			}
+			runCtx, shutdownExitCode, stopSignals := newShutdownContextWithSignals(context.Background(), shutdownSignals...)
+			defer stopSignals()
+
+			if *format == "json" {
+				layers, err := topologicalSort(cfg.Tasks)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+					return 1
+				}
+
+				results := executeConcurrent(runCtx, layers, flagCfg.Timeout)
+				summary, err := jsonMarshal(buildTaskSummaries(results))
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "ERROR: failed to encode summary: %v\n", err)
+					return 1
+				}
+				fmt.Println(string(summary))
+
+				if logger := activeLogger(); logger != nil {
+					logger.Flush()
+				}
+
+				awaitGracefulShutdown(runCtx)
+				if sc := shutdownExitCode(); sc != 0 {
+					return sc
+				}
+				return parallelExitCode(results)
+			}
+
+			if *format == "ndjson" {
+				code := runParallelNDJSON(runCtx, cfg, flagCfg.Timeout)
+				awaitGracefulShutdown(runCtx)
+				if sc := shutdownExitCode(); sc != 0 {
+					return sc
+				}
+				return code
+			}
+
 			layers, err := topologicalSort(cfg.Tasks)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
 				return 1
 			}
 
-			results := executeConcurrent(layers, timeoutSec)
+			sched, err := newConfiguredScheduler(layers, flagCfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return 1
+			}
+			if *configPath != "" {
+				stopReload := installConfigReloadHandler(sched, *configPath)
+				defer stopReload()
+			}
+			results := sched.Run(runCtx)
 			fmt.Println(generateFinalOutput(results))
+			writeRunArtifacts(flagCfg, results, layers)
 
-			exitCode = 0
-			for _, res := range results {
-				if res.ExitCode != 0 {
-					exitCode = res.ExitCode
-				}
+			if logger := activeLogger(); logger != nil {
+				logger.Flush()
+			}
+
+			awaitGracefulShutdown(runCtx)
+			if sc := shutdownExitCode(); sc != 0 {
+				return sc
+			}
+
+			return parallelExitCode(results)
+		case "--pipeline":
+			if len(os.Args) < 3 {
+				fmt.Fprintln(os.Stderr, "ERROR: usage: codex-wrapper --pipeline <file.yaml>")
+				return 1
+			}
+
+			flagSet, flagCfg := newWrapperFlagSet("codex-wrapper --pipeline")
+			flagSet.SetOutput(io.Discard)
+			if err := flagSet.Parse(os.Args[3:]); err != nil || flagSet.NArg() != 0 {
+				fmt.Fprintln(os.Stderr, "ERROR: usage: codex-wrapper --pipeline <file.yaml> [--workers N] [--retries N] ...")
+				return 1
+			}
+			applyFlagOverrides(flagSet, flagCfg)
+
+			data, err := os.ReadFile(os.Args[2])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: failed to read %s: %v\n", os.Args[2], err)
+				return 1
+			}
+			mainTasks, finallyTasks, err := parsePipelineYAML(data)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return 1
+			}
+			layers, err := buildPipelineLayers(mainTasks, finallyTasks)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return 1
+			}
+
+			runCtx, shutdownExitCode, stopSignals := newShutdownContext(context.Background())
+			defer stopSignals()
+
+			sched, err := newConfiguredScheduler(layers, flagCfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				return 1
+			}
+			results := sched.Run(runCtx)
+			fmt.Println(generateFinalOutput(results))
+			writeRunArtifacts(flagCfg, results, layers)
+
+			if logger := activeLogger(); logger != nil {
+				logger.Flush()
 			}
 
-			return exitCode
+			awaitGracefulShutdown(runCtx)
+			if sc := shutdownExitCode(); sc != 0 {
+				return sc
+			}
+			return parallelExitCode(results)
 		}
 	}
 
@@ -457,9 +1471,8 @@ func run() (exitCode int) {
 	}
 	logInfo(fmt.Sprintf("Parsed args: mode=%s, task_len=%d", cfg.Mode, len(cfg.Task)))
 
-	timeoutSec := resolveTimeout()
+	timeoutSec := cfg.Timeout
 	logInfo(fmt.Sprintf("Timeout: %ds", timeoutSec))
-	cfg.Timeout = timeoutSec
 
 	var taskText string
 	var piped bool
@@ -537,7 +1550,17 @@ func run() (exitCode int) {
 		UseStdin:  useStdin,
 	}
 
-	result := runCodexTask(taskSpec, false, cfg.Timeout)
+	result := runCodexTaskUnderDeath(taskSpec, cfg.Timeout, logger)
+
+	if cfg.Output == "json" {
+		summary, err := jsonMarshal(buildTaskSummaries([]TaskResult{result})[0])
+		if err != nil {
+			logError("failed to encode result: " + err.Error())
+			return 1
+		}
+		fmt.Println(string(summary))
+		return result.ExitCode
+	}
 
 	if result.ExitCode != 0 {
 		return result.ExitCode
@@ -551,14 +1574,128 @@ func run() (exitCode int) {
 	return 0
 }
 
+// timeoutValue is the flag.Value bound to --timeout. It accepts a bare
+// integer (seconds, mirroring CODEX_TIMEOUT's legacy heuristic where a
+// value over 10000 is treated as milliseconds) or a Go duration string
+// such as "30s" or "5m".
+type timeoutValue int
+
+func (t *timeoutValue) String() string { return strconv.Itoa(int(*t)) }
+
+func (t *timeoutValue) Set(raw string) error {
+	if secs, err := strconv.Atoi(raw); err == nil {
+		if secs <= 0 {
+			return fmt.Errorf("timeout must be positive")
+		}
+		if secs > 10000 {
+			secs /= 1000
+		}
+		*t = timeoutValue(secs)
+		return nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid timeout %q: want seconds or a duration like 30s", raw)
+	}
+	*t = timeoutValue(d / time.Second)
+	return nil
+}
+
+// newWrapperFlagSet builds the flag.FlagSet shared by the single-task
+// wrapper and --parallel: --timeout, --workdir, --session, --workers,
+// --log-level, --log-format, --stream-format, --retries and --output.
+// Every flag defaults to its matching CODEX_* environment variable, so
+// parsing an argv with no flags reproduces today's env-driven behavior
+// exactly; anything passed on the command line overrides it and, via
+// applyFlagOverrides, is re-exported into the environment so the rest of
+// the wrapper (which still reads os.Getenv directly) honors it too.
+func newWrapperFlagSet(name string) (*flag.FlagSet, *Config) {
+	cfg := &Config{
+		WorkDir:       getEnv("CODEX_WORKDIR", defaultWorkdir),
+		SessionID:     os.Getenv("CODEX_SESSION"),
+		Timeout:       resolveTimeout(),
+		Workers:       resolveMaxWorkers(),
+		Retries:       resolveMaxRetries(),
+		LogLevel:      getEnv("CODEX_LOG_LEVEL", "debug"),
+		LogFormat:     getEnv("CODEX_LOG_FORMAT", "text"),
+		StreamFormat:  getEnv("CODEX_STREAM_FORMAT", "auto"),
+		Output:        "text",
+		ShutdownGrace: shutdownGracePeriod,
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&cfg.WorkDir, "workdir", cfg.WorkDir, "working directory for the task (env CODEX_WORKDIR)")
+	fs.StringVar(&cfg.SessionID, "session", cfg.SessionID, "session id to resume (env CODEX_SESSION)")
+	fs.Var((*timeoutValue)(&cfg.Timeout), "timeout", "task timeout: seconds, or a duration like 30s/5m (env CODEX_TIMEOUT)")
+	fs.IntVar(&cfg.Workers, "workers", cfg.Workers, "max concurrent --parallel tasks (env CODEX_PARALLEL_WORKERS)")
+	fs.IntVar(&cfg.Retries, "retries", cfg.Retries, "retries per task on transient failure (env CODEX_MAX_RETRIES)")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "minimum log level kept by the default sink (env CODEX_LOG_LEVEL)")
+	fs.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "log sink format: text|json|logfmt (env CODEX_LOG_FORMAT)")
+	fs.StringVar(&cfg.StreamFormat, "stream-format", cfg.StreamFormat, "codex stdout framing: auto|ndjson|sse|text (env CODEX_STREAM_FORMAT)")
+	fs.StringVar(&cfg.Output, "output", cfg.Output, "result output: json|text")
+	fs.BoolVar(&cfg.FailFast, "fail-fast", cfg.FailFast, "cancel a layer's remaining tasks as soon as one of them fails (--parallel/--pipeline only)")
+	fs.DurationVar(&cfg.ShutdownGrace, "shutdown-grace", cfg.ShutdownGrace, "grace period to await in-flight tasks after a cancellation before force-returning")
+	fs.StringVar(&cfg.ReportPath, "report", cfg.ReportPath, "write a JSONL run report (one line per task plus a summary line) to this path (--parallel/--pipeline only)")
+	fs.StringVar(&cfg.MetricsPath, "metrics", cfg.MetricsPath, "write Prometheus text-format metrics for this run to this path (--parallel/--pipeline only)")
+	fs.StringVar(&cfg.StatePath, "state", cfg.StatePath, "checkpoint DAG state to this path at every layer boundary (--parallel/--pipeline only)")
+	fs.StringVar(&cfg.ResumePath, "resume", cfg.ResumePath, "resume from a --state checkpoint at this path, skipping tasks already recorded succeeded (--parallel/--pipeline only)")
+	return fs, cfg
+}
+
+// flagDefaults renders newWrapperFlagSet's flags (as registered, with their
+// resolved env-derived defaults) via flag.PrintDefaults, so printHelp's
+// flag list can't drift from what parseArgs actually accepts.
+func flagDefaults() string {
+	fs, _ := newWrapperFlagSet("codex-wrapper")
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.PrintDefaults()
+	return buf.String()
+}
+
+// applyFlagOverrides exports every --flag explicitly passed on the command
+// line into its matching CODEX_* env var, so callees that read the env var
+// directly (resolveMaxWorkers, resolveMaxRetries, NewLogger, the stream
+// parser, ...) see the override without needing a Config threaded through.
+func applyFlagOverrides(fs *flag.FlagSet, cfg *Config) {
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "timeout":
+			os.Setenv("CODEX_TIMEOUT", strconv.Itoa(cfg.Timeout))
+		case "workers":
+			os.Setenv("CODEX_PARALLEL_WORKERS", strconv.Itoa(cfg.Workers))
+		case "retries":
+			os.Setenv("CODEX_MAX_RETRIES", strconv.Itoa(cfg.Retries))
+		case "log-level":
+			os.Setenv("CODEX_LOG_LEVEL", cfg.LogLevel)
+		case "log-format":
+			os.Setenv("CODEX_LOG_FORMAT", cfg.LogFormat)
+		case "stream-format":
+			os.Setenv("CODEX_STREAM_FORMAT", cfg.StreamFormat)
+		case "workdir":
+			os.Setenv("CODEX_WORKDIR", cfg.WorkDir)
+		case "session":
+			os.Setenv("CODEX_SESSION", cfg.SessionID)
+		}
+	})
+}
+
+// parseArgs parses argv into a Config. Flags (see newWrapperFlagSet) may
+// precede the positional syntax kept for backward compatibility:
+// "task" [workdir] or resume <session_id> "task" [workdir].
 func parseArgs() (*Config, error) {
-	args := os.Args[1:]
+	fs, cfg := newWrapperFlagSet("codex-wrapper")
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return nil, err
+	}
+	applyFlagOverrides(fs, cfg)
+
+	args := fs.Args()
 	if len(args) == 0 {
 		return nil, fmt.Errorf("task required")
 	}
 
-	cfg := &Config{WorkDir: defaultWorkdir}
-
 	if args[0] == "resume" {
 		if len(args) < 3 {
 			return nil, fmt.Errorf("resume mode requires: resume <session_id> <task>")
@@ -639,13 +1776,64 @@ func runCodexTask(taskSpec TaskSpec, silent bool, timeoutSec int) TaskResult {
 	return runCodexTaskWithContext(context.Background(), taskSpec, nil, false, silent, timeoutSec)
 }
 
+// runCodexTaskUnderDeath runs the single-task (non-batch) path's one and
+// only codex invocation under a Death: as soon as the child process starts,
+// a SIGINT/SIGTERM/SIGHUP kills it and drains logger concurrently under one
+// shared shutdownGrace deadline, instead of each owning its own ad-hoc
+// timeout the way runCodexTaskExec's default per-call signal.NotifyContext
+// handling does. Only called once per process (run()'s single-task branch),
+// so unlike --parallel/--pipeline this can afford to own the whole
+// process's shutdown instead of layering per-task.
+func runCodexTaskUnderDeath(taskSpec TaskSpec, timeoutSec int, logger *Logger) TaskResult {
+	d := NewDeath(shutdownGrace(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	cmdCh := make(chan deathTarget, 1)
+	watcherDone := make(chan struct{})
+	go func() {
+		defer close(watcherDone)
+		select {
+		case target := <-cmdCh:
+			d.WaitForDeath(logger, target.cmd, target.exited)
+		case <-d.stopped:
+		}
+	}()
+
+	result := runCodexTaskWithContext(contextWithDeathSink(context.Background(), cmdCh), taskSpec, nil, false, false, timeoutSec)
+
+	d.Stop()
+	<-watcherDone
+	return result
+}
+
 func runCodexProcess(parentCtx context.Context, codexArgs []string, taskText string, useStdin bool, timeoutSec int) (message, threadID string, exitCode int) {
 	res := runCodexTaskWithContext(parentCtx, TaskSpec{Task: taskText, WorkDir: defaultWorkdir, Mode: "new", UseStdin: useStdin}, codexArgs, true, false, timeoutSec)
 	return res.Message, res.SessionID, res.ExitCode
 }
 
+// runCodexTaskWithContext wraps runCodexTaskExec with the metrics collection
+// shared by the sequential and --parallel execution paths: an in-flight
+// gauge, a per-task duration observation, and a tasks_total{status} count.
 func runCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, customArgs []string, useCustomArgs bool, silent bool, timeoutSec int) TaskResult {
-	result := TaskResult{TaskID: taskSpec.ID}
+	metricsRegistry.TasksInFlight.Inc()
+	start := time.Now()
+
+	result := runCodexTaskExec(parentCtx, taskSpec, customArgs, useCustomArgs, silent, timeoutSec)
+
+	metricsRegistry.TasksInFlight.Dec()
+	duration := time.Since(start)
+	metricsRegistry.TaskDuration.Observe(idPrefixLabel(taskSpec.ID), duration.Seconds())
+	status := "success"
+	if result.ExitCode != 0 || result.Error != "" {
+		status = "failure"
+	}
+	metricsRegistry.TasksTotal.Inc(status)
+	exportStore.RecordResult(taskSpec.ID, result, duration)
+
+	return result
+}
+
+func runCodexTaskExec(parentCtx context.Context, taskSpec TaskSpec, customArgs []string, useCustomArgs bool, silent bool, timeoutSec int) (result TaskResult) {
+	result = TaskResult{TaskID: taskSpec.ID}
 
 	cfg := &Config{
 		Mode:      taskSpec.Mode,
@@ -673,48 +1861,15 @@ func runCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, custo
 		codexArgs = buildCodexArgsFn(cfg, targetArg)
 	}
 
-	prefixMsg := func(msg string) string {
-		if taskSpec.ID == "" {
-			return msg
-		}
-		return fmt.Sprintf("[Task: %s] %s", taskSpec.ID, msg)
-	}
-
-	var logInfoFn func(string)
-	var logWarnFn func(string)
-	var logErrorFn func(string)
-
-	if silent {
-		// Silent mode: only persist to file when available; avoid stderr noise.
-		logInfoFn = func(msg string) {
-			if logger := activeLogger(); logger != nil {
-				logger.Info(prefixMsg(msg))
-			}
-		}
-		logWarnFn = func(msg string) {
-			if logger := activeLogger(); logger != nil {
-				logger.Warn(prefixMsg(msg))
-			}
-		}
-		logErrorFn = func(msg string) {
-			if logger := activeLogger(); logger != nil {
-				logger.Error(prefixMsg(msg))
-			}
-		}
-	} else {
-		logInfoFn = func(msg string) { logInfo(prefixMsg(msg)) }
-		logWarnFn = func(msg string) { logWarn(prefixMsg(msg)) }
-		logErrorFn = func(msg string) { logError(prefixMsg(msg)) }
-	}
-
 	stderrBuf := &tailBuffer{limit: stderrCaptureLimit}
 
 	var stdoutLogger *logWriter
 	var stderrLogger *logWriter
 
 	var tempLogger *Logger
-	if silent && activeLogger() == nil {
-		if l, err := NewLogger(); err == nil {
+	if activeLogger() == nil {
+		tempOpts := append(stderrSinkOpts(!silent), logRotationOptsFromEnv()...)
+		if l, err := NewLogger(tempOpts...); err == nil {
 			setLogger(l)
 			tempLogger = l
 		}
@@ -725,9 +1880,27 @@ func runCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, custo
 		}
 	}()
 
+	// taskLogger carries this task's ID as structured context instead of the
+	// old "[Task: x] " string prefix, so every sink (including JSONFormatter)
+	// can filter and correlate per task without parsing message text. Silent
+	// mode needs no separate branch: a nil *Logger already no-ops on every
+	// call, same as the old silent-only guards did by hand.
+	taskLogger := activeLogger()
+	if taskSpec.ID != "" {
+		taskLogger = taskLogger.With("task_id", taskSpec.ID)
+	}
+	logInfoFn := func(msg string) { taskLogger.Info(msg) }
+	logWarnFn := func(msg string) { taskLogger.Warn(msg) }
+	logErrorFn := func(msg string) { taskLogger.Error(msg) }
+
 	if !silent {
-		stdoutLogger = newLogWriter("CODEX_STDOUT: ", codexLogLineLimit)
-		stderrLogger = newLogWriter("CODEX_STDERR: ", codexLogLineLimit)
+		stdoutLogger = newLogWriter("CODEX_STDOUT: ", codexLogLineLimit, taskLogger)
+		stderrLogger = newLogWriter("CODEX_STDERR: ", codexLogLineLimit, taskLogger)
+	}
+
+	if injected, ok := chaosMonkeys.ShortCircuit(); ok {
+		logErrorFn(fmt.Sprintf("chaos: short-circuited before starting codex, exit %d", injected.ExitCode))
+		return injected
 	}
 
 	ctx := parentCtx
@@ -745,11 +1918,31 @@ func runCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, custo
 	}
 
 	cmd := commandContext(ctx, codexCommand, codexArgs...)
+	if len(taskSpec.Env) > 0 {
+		cmd.Env = append(os.Environ(), taskEnvPairs(taskSpec.Env)...)
+	}
+	// Setpgid puts codex in its own process group so terminateProcess can
+	// signal that whole group: a killed shell script's own children are
+	// otherwise reparented to PID 1 but keep our stdout/stderr pipes open,
+	// which wedges cmd.Wait() for however long they keep running.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// Captured once so a concurrent change to the package-level sink mid-task
+	// can't split one task's events between an old and a new subscriber.
+	eventSink := taskEventSink
+	var stdoutChunks, stderrChunks *chunkEventWriter
+	if eventSink != nil {
+		stdoutChunks = &chunkEventWriter{taskID: taskSpec.ID, state: TaskStdoutChunk, sink: eventSink}
+		stderrChunks = &chunkEventWriter{taskID: taskSpec.ID, state: TaskStderrChunk, sink: eventSink}
+	}
 
 	stderrWriters := []io.Writer{stderrBuf}
 	if stderrLogger != nil {
 		stderrWriters = append(stderrWriters, stderrLogger)
 	}
+	if stderrChunks != nil {
+		stderrWriters = append(stderrWriters, stderrChunks)
+	}
 	if !silent {
 		stderrWriters = append([]io.Writer{os.Stderr}, stderrWriters...)
 	}
@@ -779,13 +1972,26 @@ func runCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, custo
 		return result
 	}
 
-	stdoutReader := io.Reader(stdout)
+	stdoutReader := chaosMonkeys.WrapStdout(io.Reader(stdout))
+	var stdoutTeeWriters []io.Writer
 	if stdoutLogger != nil {
-		stdoutReader = io.TeeReader(stdout, stdoutLogger)
+		stdoutTeeWriters = append(stdoutTeeWriters, stdoutLogger)
+	}
+	if stdoutChunks != nil {
+		stdoutTeeWriters = append(stdoutTeeWriters, stdoutChunks)
+	}
+	switch len(stdoutTeeWriters) {
+	case 0:
+	case 1:
+		stdoutReader = io.TeeReader(stdout, stdoutTeeWriters[0])
+	default:
+		stdoutReader = io.TeeReader(stdout, io.MultiWriter(stdoutTeeWriters...))
 	}
 
 	logInfoFn(fmt.Sprintf("Starting codex with args: codex %s...", strings.Join(codexArgs[:min(5, len(codexArgs))], " ")))
 
+	chaosMonkeys.BeforeStart(ctx)
+
 	if err := cmd.Start(); err != nil {
 		if strings.Contains(err.Error(), "executable file not found") {
 			logErrorFn("codex command not found in PATH")
@@ -800,10 +2006,41 @@ func runCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, custo
 	}
 
 	logInfoFn(fmt.Sprintf("Starting codex with PID: %d", cmd.Process.Pid))
+
+	// exited is handed to Death (via the sink below) the moment the process
+	// starts, but isn't closed until the cmd.Wait() goroutine further down
+	// actually reaps it - that goroutine's launch stays where it's always
+	// been, right before parseCh's, so moving this plumbing earlier doesn't
+	// change the existing Wait()-vs-stdout-read race window for fast-exiting
+	// commands.
+	waitCh := make(chan error, 1)
+	exited := make(chan struct{})
+
+	if sink, ok := ctx.Value(deathCmdSinkKey{}).(chan<- deathTarget); ok {
+		sink <- deathTarget{cmd: cmd, exited: exited}
+	}
 	if logger := activeLogger(); logger != nil {
 		logInfoFn(fmt.Sprintf("Log capturing to: %s", logger.Path()))
 	}
 
+	if stop := chaosMonkeys.AfterStart(cmd); stop != nil {
+		defer stop()
+	}
+
+	wallStart := time.Now()
+	emitTaskEvent(eventSink, TaskEvent{TaskID: taskSpec.ID, State: TaskRunning, PID: cmd.Process.Pid, StartedAt: &wallStart})
+
+	cg := newCgroupForTask(taskSpec, cmd.Process.Pid, logWarnFn)
+	cg.addPID(cmd.Process.Pid)
+	defer func() {
+		result.WallTimeMs = time.Since(wallStart).Milliseconds()
+		stats := cg.readStats()
+		result.CPUTimeMs = stats.CPUTimeMs
+		result.PeakMemoryBytes = stats.PeakMemoryBytes
+		result.PeakPIDs = stats.PeakPIDs
+		cg.cleanup()
+	}()
+
 	if useStdin && stdinPipe != nil {
 		logInfoFn(fmt.Sprintf("Writing %d chars to stdin...", len(taskSpec.Task)))
 		go func(data string) {
@@ -813,12 +2050,15 @@ func runCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, custo
 		logInfoFn("Stdin closed")
 	}
 
-	waitCh := make(chan error, 1)
-	go func() { waitCh <- cmd.Wait() }()
+	go func() {
+		err := cmd.Wait()
+		waitCh <- err
+		close(exited)
+	}()
 
 	parseCh := make(chan parseResult, 1)
 	go func() {
-		msg, tid := parseJSONStreamWithLog(stdoutReader, logWarnFn, logInfoFn)
+		msg, tid := streamParserFromEnv().Parse(stdoutReader, taskLogger)
 		parseCh <- parseResult{message: msg, threadID: tid}
 	}()
 
@@ -829,7 +2069,7 @@ func runCodexTaskWithContext(parentCtx context.Context, taskSpec TaskSpec, custo
 	case waitErr = <-waitCh:
 	case <-ctx.Done():
 		logErrorFn(cancelReason(ctx))
-		forceKillTimer = terminateProcess(cmd)
+		forceKillTimer = terminateProcess(cmd, time.Duration(forceKillDelay)*time.Second)
 		waitErr = <-waitCh
 	}
 
@@ -951,39 +2191,95 @@ func cancelReason(ctx context.Context) string {
 	return "Execution cancelled, terminating codex process"
 }
 
-func terminateProcess(cmd *exec.Cmd) *time.Timer {
+// terminateProcess sends SIGTERM to cmd's process group and escalates to
+// SIGKILL after escalateAfter if the process hasn't exited by then. Callers
+// with their own overall deadline (e.g. Death) pass the remaining budget
+// instead of forceKillDelay so the escalation timer never outlives it.
+func terminateProcess(cmd *exec.Cmd, escalateAfter time.Duration) *time.Timer {
 	if cmd == nil || cmd.Process == nil {
 		return nil
 	}
 
-	_ = cmd.Process.Signal(syscall.SIGTERM)
+	signalGroup(cmd, syscall.SIGTERM)
 
-	return time.AfterFunc(time.Duration(forceKillDelay)*time.Second, func() {
-		if cmd.Process != nil {
-			_ = cmd.Process.Kill()
-		}
+	return time.AfterFunc(escalateAfter, func() {
+		signalGroup(cmd, syscall.SIGKILL)
 	})
 }
 
-func parseJSONStream(r io.Reader) (message, threadID string) {
-	return parseJSONStreamWithLog(r, logWarn, logInfo)
+// signalGroup signals cmd's whole process group (cmd is started with
+// Setpgid: true, so its PID doubles as the group ID) rather than just the
+// direct child, so a killed shell script's own children die with it instead
+// of outliving it as PID-1 orphans that keep cmd.Wait() blocked on open
+// stdout/stderr pipes. Falls back to signaling the process alone if the
+// group call fails (e.g. it has already exited).
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) {
+	if err := syscall.Kill(-cmd.Process.Pid, sig); err != nil {
+		_ = cmd.Process.Signal(sig)
+	}
 }
 
-func parseJSONStreamWithWarn(r io.Reader, warnFn func(string)) (message, threadID string) {
-	return parseJSONStreamWithLog(r, warnFn, logInfo)
+// parseJSONStream parses codex's NDJSON stdout using the active logger (see
+// setLogger/activeLogger), binding thread_id/event_seq as structured fields
+// on each record instead of interpolating them into the message text. A nil
+// active logger (no logger installed) is a safe no-op, same as every other
+// Logger method.
+func parseJSONStream(r io.Reader) (message, threadID string) {
+	return parseJSONStreamWithLogger(r, activeLogger())
 }
 
-func parseJSONStreamWithLog(r io.Reader, warnFn func(string), infoFn func(string)) (message, threadID string) {
+// parseJSONStreamWithWarn parses r exactly as parseJSONStream does, but
+// reports line-parse and read failures to warnFn instead of a *Logger. Kept
+// for callers that only need failure visibility and don't have a bound
+// logger to hand (e.g. one-off scripts, simple tests).
+func parseJSONStreamWithWarn(r io.Reader, warnFn func(string)) (message, threadID string) {
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
 
 	if warnFn == nil {
 		warnFn = func(string) {}
 	}
-	if infoFn == nil {
-		infoFn = func(string) {}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var event JSONEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			warnFn(fmt.Sprintf("Failed to parse line: %s", truncate(line, 100)))
+			continue
+		}
+
+		switch event.Type {
+		case "thread.started":
+			threadID = event.ThreadID
+		case "item.completed":
+			if event.Item != nil && event.Item.Type == "agent_message" {
+				if normalized := normalizeText(event.Item.Text); normalized != "" {
+					message = normalized
+				}
+			}
+		}
 	}
 
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		warnFn("Read stdout error: " + err.Error())
+	}
+
+	return message, threadID
+}
+
+// parseJSONStreamWithLogger parses codex's NDJSON stdout, deriving a child
+// logger per event (via Logger.With/New) that carries event_seq, event_type
+// and thread_id as structured fields, so downstream sinks (e.g. JSONFormatter)
+// can filter and correlate on them without parsing the message text. A nil
+// logger is a safe no-op throughout, same as every other Logger method.
+func parseJSONStreamWithLogger(r io.Reader, logger *Logger) (message, threadID string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
 	totalEvents := 0
 
 	for scanner.Scan() {
@@ -992,30 +2288,29 @@ func parseJSONStreamWithLog(r io.Reader, warnFn func(string), infoFn func(string
 			continue
 		}
 		totalEvents++
+		evLogger := logger.New("event_seq", totalEvents)
 
 		var event JSONEvent
 		if err := json.Unmarshal([]byte(line), &event); err != nil {
-			warnFn(fmt.Sprintf("Failed to parse line: %s", truncate(line, 100)))
+			evLogger.Warn("Failed to parse JSON line", "snippet", truncate(line, 100))
 			continue
 		}
 
-		var details []string
 		if event.ThreadID != "" {
-			details = append(details, fmt.Sprintf("thread_id=%s", event.ThreadID))
+			threadID = event.ThreadID
 		}
-		if event.Item != nil && event.Item.Type != "" {
-			details = append(details, fmt.Sprintf("item_type=%s", event.Item.Type))
+		evLogger = evLogger.New("event_type", event.Type)
+		if threadID != "" {
+			evLogger = evLogger.New("thread_id", threadID)
 		}
-		if len(details) > 0 {
-			infoFn(fmt.Sprintf("Parsed event #%d type=%s (%s)", totalEvents, event.Type, strings.Join(details, ", ")))
-		} else {
-			infoFn(fmt.Sprintf("Parsed event #%d type=%s", totalEvents, event.Type))
+		if event.Item != nil && event.Item.Type != "" {
+			evLogger = evLogger.New("item_type", event.Item.Type)
 		}
+		evLogger.Info("Parsed codex event")
 
 		switch event.Type {
 		case "thread.started":
-			threadID = event.ThreadID
-			infoFn(fmt.Sprintf("thread.started event thread_id=%s", threadID))
+			evLogger.Info("thread.started event")
 		case "item.completed":
 			var itemType string
 			var normalized string
@@ -1023,18 +2318,19 @@ func parseJSONStreamWithLog(r io.Reader, warnFn func(string), infoFn func(string
 				itemType = event.Item.Type
 				normalized = normalizeText(event.Item.Text)
 			}
-			infoFn(fmt.Sprintf("item.completed event item_type=%s message_len=%d", itemType, len(normalized)))
-			if event.Item != nil && event.Item.Type == "agent_message" && normalized != "" {
+			evLogger.Info("item.completed event", "message_len", len(normalized))
+			if itemType == "agent_message" && normalized != "" {
 				message = normalized
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
-		warnFn("Read stdout error: " + err.Error())
+		logger.New("event_seq", totalEvents).Warn("Read stdout error", "error", err.Error())
 	}
 
-	infoFn(fmt.Sprintf("parseJSONStream completed: events=%d, message_len=%d, thread_id_found=%t", totalEvents, len(message), threadID != ""))
+	logger.New("event_seq", totalEvents).Info("parseJSONStream completed",
+		"message_len", len(message), "thread_id_found", threadID != "")
 	return message, threadID
 }
 
@@ -1099,6 +2395,38 @@ func resolveTimeout() int {
 	return parsed
 }
 
+// resolveMaxRetries returns CODEX_MAX_RETRIES, or defaultMaxRetries if unset
+// or invalid.
+func resolveMaxRetries() int {
+	raw := os.Getenv("CODEX_MAX_RETRIES")
+	if raw == "" {
+		return defaultMaxRetries
+	}
+
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed < 0 {
+		logWarn(fmt.Sprintf("Invalid CODEX_MAX_RETRIES '%s', falling back to %d", raw, defaultMaxRetries))
+		return defaultMaxRetries
+	}
+	return parsed
+}
+
+// resolveMaxWorkers returns CODEX_PARALLEL_WORKERS, or runtime.NumCPU() if
+// unset or invalid. It bounds how many tasks executeConcurrent runs at once.
+func resolveMaxWorkers() int {
+	raw := os.Getenv("CODEX_PARALLEL_WORKERS")
+	if raw == "" {
+		return runtime.NumCPU()
+	}
+
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed <= 0 {
+		logWarn(fmt.Sprintf("Invalid CODEX_PARALLEL_WORKERS '%s', falling back to %d", raw, runtime.NumCPU()))
+		return runtime.NumCPU()
+	}
+	return parsed
+}
+
 func defaultIsTerminal() bool {
 	fi, err := os.Stdin.Stat()
 	if err != nil {
@@ -1118,17 +2446,47 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// isInteractiveInvocation reports whether args describe a single-task run
+// (the default, a human watching a terminal) rather than --daemon/--remote/
+// --parallel, which stream machine-readable output and should stay quiet.
+func isInteractiveInvocation(args []string) bool {
+	if len(args) < 2 {
+		return true
+	}
+	switch args[1] {
+	case "--daemon", "--remote", "--parallel":
+		return false
+	default:
+		return true
+	}
+}
+
+// stderrSinkOpts returns LoggerOptions that mirror log entries to stderr,
+// text-formatted and filtered to CODEX_WRAPPER_LOG's level, when echo is
+// true. It returns nil when echo is false, leaving the logger file-only.
+func stderrSinkOpts(echo bool) []LoggerOption {
+	if !echo {
+		return nil
+	}
+	return []LoggerOption{WithSink(NewStderrSink(), TextFormatter, logLevelFromEnv())}
+}
+
+// logWriter line-buffers a codex subprocess stream and forwards each
+// complete line to logger at INFO, prefixed to say which stream it came
+// from. logger is typically a per-task Logger (see With in logger.go) so
+// the lines carry that task's context through to every sink.
 type logWriter struct {
 	prefix string
 	maxLen int
 	buf    bytes.Buffer
+	logger *Logger
 }
 
-func newLogWriter(prefix string, maxLen int) *logWriter {
+func newLogWriter(prefix string, maxLen int, logger *Logger) *logWriter {
 	if maxLen <= 0 {
 		maxLen = codexLogLineLimit
 	}
-	return &logWriter{prefix: prefix, maxLen: maxLen}
+	return &logWriter{prefix: prefix, maxLen: maxLen, logger: logger}
 }
 
 func (lw *logWriter) Write(p []byte) (int, error) {
@@ -1173,7 +2531,26 @@ func (lw *logWriter) logLine(force bool) {
 			line = line[:cutoff]
 		}
 	}
-	logInfo(lw.prefix + line)
+	lw.logger.Info(lw.prefix + line)
+}
+
+// taskEnvPairs renders env as sorted "KEY=VALUE" pairs, appended over
+// os.Environ() in runCodexTaskExec so a task's extra env vars are
+// deterministic across runs (map iteration order isn't) and still let the
+// later, more specific entries win on duplicate keys per exec.Cmd.Env's
+// documented last-one-wins behavior.
+func taskEnvPairs(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+env[k])
+	}
+	return pairs
 }
 
 func truncate(s string, maxLen int) string {
@@ -1252,11 +2629,26 @@ func printHelp() {
 	help := `codex-wrapper - Go wrapper for Codex CLI
 
 Usage:
-    codex-wrapper "task" [workdir]
-    codex-wrapper - [workdir]              Read task from stdin
-    codex-wrapper resume <session_id> "task" [workdir]
-    codex-wrapper resume <session_id> - [workdir]
-    codex-wrapper --parallel               Run tasks in parallel (config from stdin)
+    codex-wrapper [flags] "task" [workdir]
+    codex-wrapper [flags] - [workdir]      Read task from stdin
+    codex-wrapper [flags] resume <session_id> "task" [workdir]
+    codex-wrapper [flags] resume <session_id> - [workdir]
+    codex-wrapper --parallel [flags]       Run tasks in parallel (config from stdin)
+    codex-wrapper --daemon [--listen unix:///path|tcp://host:port]
+                                            Run as a long-lived DAG submission service
+    codex-wrapper --remote <addr> --parallel
+                                            Submit a DAG (config from stdin) to a running daemon
+    codex-wrapper --parallel --coordinator <local|consul://...|etcd://...> --dag <id>
+                                            Submit a DAG (config from stdin) for worker processes to claim
+    codex-wrapper --parallel --worker --coordinator <addr> --dag <id>
+                                            Join a published DAG and run whichever tasks it can claim
+    codex-wrapper --chaos <spec> --i-know-this-is-dangerous ...
+                                            Inject faults (delay, stdout-throttle, kill-after,
+                                            drop-lines, fail-rate) into every task this process runs
+    codex-wrapper --metrics-addr :9090 ...   Serve Prometheus /metrics for this process
+    codex-wrapper --push-url http://... ...  Push this process's metrics registry on an interval
+    codex-wrapper --export-push-url http://... ...
+                                            Push --parallel/--pipeline run metrics (Store) on an interval
     codex-wrapper --version
     codex-wrapper --help
 
@@ -1264,9 +2656,46 @@ Parallel mode examples:
     codex-wrapper --parallel < tasks.txt
     echo '...' | codex-wrapper --parallel
     codex-wrapper --parallel <<'EOF'
+    codex-wrapper --parallel --format ndjson < tasks.txt   Stream one TaskEvent per line on stdout
+    codex-wrapper --parallel --format json < tasks.txt     Print a JSON array of per-task summaries on completion
+    codex-wrapper --parallel --config tasks.txt            SIGHUP re-reads tasks.txt and live-replans the DAG
+
+Daemon mode examples:
+    codex-wrapper --daemon --listen unix:///tmp/codex-wrapper.sock
+    codex-wrapper --remote unix:///tmp/codex-wrapper.sock --parallel < tasks.txt
 
+Flags (single-task and --parallel; each falls back to the env var noted,
+and --parallel additionally accepts --format):
+` + flagDefaults() + `
 Environment Variables:
-    CODEX_TIMEOUT  Timeout in milliseconds (default: 7200000)
+    CODEX_TIMEOUT         Timeout in seconds, or milliseconds for back-compat
+                          values over 10000 (default: 7200); overridden by --timeout
+    CODEX_WORKDIR         Working directory for the task (default: .); overridden by --workdir
+    CODEX_SESSION         Session id to resume; overridden by --session
+    CODEX_WRAPPER_LOG     Log level: debug|info|warn|error (default: info)
+    CODEX_WRAPPER_CHAOS   Fault spec, same format as --chaos (requires --i-know-this-is-dangerous)
+    CODEX_LOG_LEVEL       Minimum level kept by the default log sink (default: debug)
+    CODEX_LOG_FORMAT      Default log sink format: text|json|logfmt (default: text)
+    CODEX_SHUTDOWN_GRACE  Seconds --parallel waits for cleanup hooks on SIGINT/SIGTERM/SIGHUP (default: 10)
+    CODEX_STREAM_FORMAT   codex stdout framing: auto|ndjson|sse|text (default: auto)
+    CODEX_PARALLEL_WORKERS  Max tasks --parallel runs at once (default: number of CPUs)
+    CODEX_MAX_RETRIES     Retries per task on transient failure (default: 2; exit 124/127 never retry)
+    CODEX_LOG_MAX_SIZE    Bytes before the log file rotates (default: unlimited)
+    CODEX_LOG_MAX_AGE     Seconds before a rotated backup is pruned (default: unlimited)
+    CODEX_LOG_MAX_BACKUPS Rotated backups retained beyond MAX_AGE pruning (default: unlimited)
+    CODEX_LOG_COMPRESS    Gzip rotated backups when set to 1|true|yes (default: off)
+    METRICS_ADDR          Serve Prometheus /metrics on this addr (e.g. :9090); overridden by --metrics-addr
+    PUSH_URL              Push the /metrics registry to this URL on an interval; overridden by --push-url
+    PUSH_INTERVAL         Push interval in milliseconds (default: 15000); overridden by --push-interval (a duration like 15s)
+    CODEX_EXPORT_PUSH_URL Push --parallel/--pipeline run metrics (separate from /metrics) to this
+                          URL, or a comma-separated list; overridden by --export-push-url
+    CODEX_EXPORT_FORMAT   Export payload format: prom|json (default: prom); overridden by --export-format
+    CODEX_EXPORT_PUSH_INTERVAL  Export push interval, a duration like 15s (default: 15s);
+                          overridden by --export-push-interval
+    CODEX_EXPORT_OMIT_TASK_LABEL  Aggregate exported metrics across all tasks when set;
+                          overridden by --export-omit-task-label
+    CODEX_EXPORT_TIMESTAMP  Add a pushed_at timestamp to each export payload when set;
+                          overridden by --export-timestamp
 
 Exit Codes:
     0    Success
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -109,8 +110,10 @@ func findResultByID(t *testing.T, payload integrationOutput, id string) TaskResu
 func TestParallelEndToEnd_OrderAndConcurrency(t *testing.T) {
 	defer resetTestHooks()
 	origRun := runCodexTaskFn
+	os.Setenv("CODEX_PARALLEL_WORKERS", "5")
 	t.Cleanup(func() {
 		runCodexTaskFn = origRun
+		os.Unsetenv("CODEX_PARALLEL_WORKERS")
 		resetTestHooks()
 	})
 
@@ -145,7 +148,7 @@ task-e`
 	var running int64
 	var maxParallel int64
 
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 		start := time.Now()
 		mu.Lock()
 		starts[task.ID] = start
@@ -220,7 +223,7 @@ task-e`
 func TestParallelCycleDetectionStopsExecution(t *testing.T) {
 	defer resetTestHooks()
 	origRun := runCodexTaskFn
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 		t.Fatalf("task %s should not execute on cycle", task.ID)
 		return TaskResult{}
 	}
@@ -263,7 +266,7 @@ func TestParallelPartialFailureBlocksDependents(t *testing.T) {
 		resetTestHooks()
 	})
 
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 		if task.ID == "A" {
 			return TaskResult{TaskID: "A", ExitCode: 2, Error: "boom"}
 		}
@@ -329,7 +332,7 @@ func TestParallelTimeoutPropagation(t *testing.T) {
 	})
 
 	var receivedTimeout int
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 		receivedTimeout = timeout
 		return TaskResult{TaskID: task.ID, ExitCode: 124, Error: "timeout"}
 	}
@@ -364,6 +367,11 @@ slow`
 }
 
 func TestConcurrentSpeedupBenchmark(t *testing.T) {
+	// Pin the worker pool instead of letting it default to runtime.NumCPU():
+	// on a single-core runner that default serializes every task and the
+	// speedup assertion below fails.
+	t.Setenv("CODEX_PARALLEL_WORKERS", "10")
+
 	defer resetTestHooks()
 	origRun := runCodexTaskFn
 	t.Cleanup(func() {
@@ -371,7 +379,7 @@ func TestConcurrentSpeedupBenchmark(t *testing.T) {
 		resetTestHooks()
 	})
 
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 		time.Sleep(50 * time.Millisecond)
 		return TaskResult{TaskID: task.ID}
 	}
@@ -384,12 +392,12 @@ func TestConcurrentSpeedupBenchmark(t *testing.T) {
 
 	serialStart := time.Now()
 	for _, task := range tasks {
-		_ = runCodexTaskFn(task, 5)
+		_ = runCodexTaskFn(context.Background(), task, 5)
 	}
 	serialElapsed := time.Since(serialStart)
 
 	concurrentStart := time.Now()
-	_ = executeConcurrent(layers, 5)
+	_ = executeConcurrent(context.Background(), layers, 5)
 	concurrentElapsed := time.Since(concurrentStart)
 
 	if concurrentElapsed >= serialElapsed/5 {
@@ -28,6 +28,8 @@ func resetTestHooks() {
 	buildCodexArgsFn = buildCodexArgs
 	commandContext = exec.CommandContext
 	jsonMarshal = json.Marshal
+	taskEventSink = nil
+	logRotateFn = func(l *Logger) error { return l.Rotate() }
 	closeLogger()
 }
 
@@ -291,6 +293,279 @@ code with special chars: $var "quotes"`
 	}
 }
 
+func TestParseParallelConfig_JSONFormat(t *testing.T) {
+	input := `{"tasks": [
+		{"id": "T1", "task": "echo 'test'", "workdir": "/tmp"},
+		{"id": "T2", "task": "build", "dependencies": ["T1"], "timeout": 120, "env": {"FOO": "bar"}}
+	]}`
+
+	cfg, err := parseParallelConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("parseParallelConfig() error = %v", err)
+	}
+	if len(cfg.Tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(cfg.Tasks))
+	}
+	if cfg.Tasks[0].WorkDir != "/tmp" {
+		t.Fatalf("expected explicit workdir preserved, got %q", cfg.Tasks[0].WorkDir)
+	}
+	t2 := cfg.Tasks[1]
+	if len(t2.Dependencies) != 1 || t2.Dependencies[0] != "T1" {
+		t.Fatalf("expected T2 to depend on T1, got %+v", t2.Dependencies)
+	}
+	if t2.Timeout != 120 {
+		t.Fatalf("expected timeout 120, got %d", t2.Timeout)
+	}
+	if t2.Env["FOO"] != "bar" {
+		t.Fatalf("expected env FOO=bar, got %+v", t2.Env)
+	}
+}
+
+func TestParseParallelConfig_JSONFormat_DuplicateID(t *testing.T) {
+	input := `{"tasks": [{"id": "dup", "task": "one"}, {"id": "dup", "task": "two"}]}`
+	if _, err := parseParallelConfig([]byte(input)); err == nil {
+		t.Fatalf("expected error for duplicate id, got nil")
+	}
+}
+
+func TestParseParallelConfig_YAMLFormat(t *testing.T) {
+	input := `---
+tasks:
+  - id: T1
+    workdir: /tmp
+    content: |
+      echo 'test'
+  - id: T2
+    dependencies:
+      - T1
+    retry: 3
+    timeout: 90
+    env:
+      FOO: bar
+      BAZ: qux
+    content: |
+      build the thing
+      second line`
+
+	cfg, err := parseParallelConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("parseParallelConfig() error = %v", err)
+	}
+	if len(cfg.Tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(cfg.Tasks))
+	}
+
+	t1 := cfg.Tasks[0]
+	if t1.ID != "T1" || t1.WorkDir != "/tmp" || t1.Task != "echo 'test'" {
+		t.Fatalf("unexpected T1: %+v", t1)
+	}
+
+	t2 := cfg.Tasks[1]
+	if len(t2.Dependencies) != 1 || t2.Dependencies[0] != "T1" {
+		t.Fatalf("expected T2 to depend on T1, got %+v", t2.Dependencies)
+	}
+	if t2.Retries != 3 {
+		t.Fatalf("expected retry 3, got %d", t2.Retries)
+	}
+	if t2.Timeout != 90 {
+		t.Fatalf("expected timeout 90, got %d", t2.Timeout)
+	}
+	if t2.Env["FOO"] != "bar" || t2.Env["BAZ"] != "qux" {
+		t.Fatalf("unexpected env: %+v", t2.Env)
+	}
+	if t2.Task != "build the thing\nsecond line" {
+		t.Fatalf("unexpected content: %q", t2.Task)
+	}
+}
+
+func TestParseParallelConfig_YAMLFormat_InlineDependencies(t *testing.T) {
+	input := `tasks:
+  - id: T1
+    content: |
+      one
+  - id: T2
+    dependencies: [T1]
+    content: |
+      two`
+
+	cfg, err := parseParallelConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("parseParallelConfig() error = %v", err)
+	}
+	if len(cfg.Tasks) != 2 || len(cfg.Tasks[1].Dependencies) != 1 || cfg.Tasks[1].Dependencies[0] != "T1" {
+		t.Fatalf("unexpected tasks: %+v", cfg.Tasks)
+	}
+}
+
+func TestParseParallelConfig_YAMLFormat_DuplicateID(t *testing.T) {
+	input := `tasks:
+  - id: dup
+    content: |
+      one
+  - id: dup
+    content: |
+      two`
+	if _, err := parseParallelConfig([]byte(input)); err == nil {
+		t.Fatalf("expected error for duplicate id, got nil")
+	}
+}
+
+func TestParseParallelConfig_YAMLFormat_MissingTasksKey(t *testing.T) {
+	input := `---
+not_tasks:
+  - id: T1`
+	if _, err := parseParallelConfig([]byte(input)); err == nil {
+		t.Fatalf("expected error for missing tasks key, got nil")
+	}
+}
+
+// TestParseParallelConfig_CycleDetectionAcrossFormats asserts topologicalSort
+// rejects a cyclic DAG the same way regardless of which parser produced the
+// TaskSpecs, since cycle detection lives downstream of all three parsers.
+func TestParseParallelConfig_CycleDetectionAcrossFormats(t *testing.T) {
+	cases := map[string]string{
+		"delimiter": `---TASK---
+id: a
+dependencies: b
+---CONTENT---
+one
+---TASK---
+id: b
+dependencies: a
+---CONTENT---
+two`,
+		"json": `{"tasks": [{"id": "a", "task": "one", "dependencies": ["b"]}, {"id": "b", "task": "two", "dependencies": ["a"]}]}`,
+		"yaml": `tasks:
+  - id: a
+    dependencies: [b]
+    content: |
+      one
+  - id: b
+    dependencies: [a]
+    content: |
+      two`,
+	}
+
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := parseParallelConfig([]byte(input))
+			if err != nil {
+				t.Fatalf("parseParallelConfig() error = %v", err)
+			}
+			if _, err := topologicalSort(cfg.Tasks); err == nil {
+				t.Fatalf("expected cycle detection error, got nil")
+			}
+		})
+	}
+}
+
+func TestParsePipelineYAML_ValidPipelineLayersAndOrdering(t *testing.T) {
+	manifest := `tasks:
+  - name: build
+    taskSpec:
+      content: |
+        build it
+  - name: test
+    runAfter: [build]
+    taskSpec:
+      content: |
+        test it
+finally:
+  - name: notify
+    taskSpec:
+      content: |
+        notify`
+
+	main, finally, err := parsePipelineYAML([]byte(manifest))
+	if err != nil {
+		t.Fatalf("parsePipelineYAML() error = %v", err)
+	}
+	layers, err := buildPipelineLayers(main, finally)
+	if err != nil {
+		t.Fatalf("buildPipelineLayers() error = %v", err)
+	}
+
+	if len(layers) != 3 {
+		t.Fatalf("expected 3 layers (build, test, notify), got %d: %+v", len(layers), layers)
+	}
+	if layers[0][0].ID != "build" || layers[1][0].ID != "test" || layers[2][0].ID != "notify" {
+		t.Fatalf("unexpected layer ordering: %+v", layers)
+	}
+	if !layers[2][0].Finally {
+		t.Fatalf("expected notify to be marked Finally, got %+v", layers[2][0])
+	}
+}
+
+func TestParsePipelineYAML_CycleInRunAfterDetected(t *testing.T) {
+	manifest := `tasks:
+  - name: a
+    runAfter: [b]
+    taskSpec:
+      content: |
+        one
+  - name: b
+    runAfter: [a]
+    taskSpec:
+      content: |
+        two`
+
+	main, finally, err := parsePipelineYAML([]byte(manifest))
+	if err != nil {
+		t.Fatalf("parsePipelineYAML() error = %v", err)
+	}
+	_, err = buildPipelineLayers(main, finally)
+	if err == nil || !strings.Contains(err.Error(), "cycle detected") {
+		t.Fatalf("expected cycle detected error, got %v", err)
+	}
+}
+
+func TestParsePipelineYAML_UnknownRunAfterReference(t *testing.T) {
+	manifest := `tasks:
+  - name: a
+    runAfter: [missing]
+    taskSpec:
+      content: |
+        one`
+
+	main, finally, err := parsePipelineYAML([]byte(manifest))
+	if err != nil {
+		t.Fatalf("parsePipelineYAML() error = %v", err)
+	}
+	_, err = buildPipelineLayers(main, finally)
+	if err == nil || !strings.Contains(err.Error(), `dependency "missing" not found`) {
+		t.Fatalf("expected dependency not found error, got %v", err)
+	}
+}
+
+func TestParsePipelineYAML_FinallyRunsDespiteMainFailureAndBypassesSkip(t *testing.T) {
+	manifest := `tasks:
+  - name: a
+    taskSpec:
+      content: |
+        fails
+finally:
+  - name: cleanup
+    runAfter: [a]
+    taskSpec:
+      content: |
+        cleanup`
+
+	main, finally, err := parsePipelineYAML([]byte(manifest))
+	if err != nil {
+		t.Fatalf("parsePipelineYAML() error = %v", err)
+	}
+	layers, err := buildPipelineLayers(main, finally)
+	if err != nil {
+		t.Fatalf("buildPipelineLayers() error = %v", err)
+	}
+
+	failed := map[string]TaskResult{"a": {TaskID: "a", ExitCode: 1, Error: "boom"}}
+	cleanup := layers[len(layers)-1][0]
+	if skip, reason := shouldSkipTask(cleanup, failed); skip {
+		t.Fatalf("expected finally task to bypass shouldSkipTask, got skip=true reason=%q", reason)
+	}
+}
+
 func TestShouldUseStdin(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -453,6 +728,46 @@ func TestParseJSONStreamWithWarn_InvalidLine(t *testing.T) {
 	}
 }
 
+func TestParseJSONStreamWithLogger_BindsStructuredFields(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	input := `{"type":"thread.started","thread_id":"tid-struct"}
+{"type":"item.completed","item":{"type":"agent_message","text":"hi"}}
+not-json`
+
+	message, threadID := parseJSONStreamWithLogger(strings.NewReader(input), logger)
+	if message != "hi" || threadID != "tid-struct" {
+		t.Fatalf("message=%q threadID=%q, want message=hi threadID=tid-struct", message, threadID)
+	}
+	logger.Flush()
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+
+	content := string(data)
+	for _, want := range []string{"thread_id=tid-struct", "event_seq=1", "event_type=thread.started"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("log file missing %q, content: %s", want, content)
+		}
+	}
+}
+
+func TestParseJSONStreamWithLogger_NilLoggerIsNoop(t *testing.T) {
+	message, threadID := parseJSONStreamWithLogger(strings.NewReader(`{"type":"item.completed","item":{"type":"agent_message","text":"ok"}}`), nil)
+	if message != "ok" || threadID != "" {
+		t.Fatalf("message=%q threadID=%q, want message=ok threadID=empty", message, threadID)
+	}
+}
+
 func TestRunGetEnv(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -696,6 +1011,27 @@ func TestRunCodexTask_WithStdin(t *testing.T) {
 	}
 }
 
+func TestRunCodexTask_WithEnv(t *testing.T) {
+	defer resetTestHooks()
+	codexCommand = "sh"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string {
+		return []string{"-c", `echo '{"type":"item.completed","item":{"type":"agent_message","text":"'"$CODEX_WRAPPER_TEST_ENV"'"}}'`}
+	}
+
+	res := runCodexTask(TaskSpec{Task: "noop", Env: map[string]string{"CODEX_WRAPPER_TEST_ENV": "injected"}}, false, 10)
+	if res.ExitCode != 0 || res.Message != "injected" {
+		t.Fatalf("expected task env var to reach the subprocess, got %+v", res)
+	}
+}
+
+func TestTaskEnvPairs_SortedDeterministicOrder(t *testing.T) {
+	got := taskEnvPairs(map[string]string{"B": "2", "A": "1"})
+	want := []string{"A=1", "B=2"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("taskEnvPairs() = %v, want %v", got, want)
+	}
+}
+
 func TestRunCodexTask_ExitError(t *testing.T) {
 	defer resetTestHooks()
 	codexCommand = "false"
@@ -763,6 +1099,65 @@ func TestRunCodexTask_SignalHandling(t *testing.T) {
 	}
 }
 
+func TestRunCodexTaskUnderDeath_SignalKillsChildAndReturns(t *testing.T) {
+	defer resetTestHooks()
+	codexCommand = "sleep"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{"5"} }
+
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	resultCh := make(chan TaskResult, 1)
+	go func() { resultCh <- runCodexTaskUnderDeath(TaskSpec{Task: "ignored"}, 5, logger) }()
+
+	time.Sleep(200 * time.Millisecond)
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+
+	select {
+	case res := <-resultCh:
+		if res.ExitCode == 0 || res.Error == "" {
+			t.Fatalf("expected non-zero exit after signal, got %+v", res)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("runCodexTaskUnderDeath did not return after SIGTERM")
+	}
+	signal.Reset(syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+}
+
+func TestRunCodexTaskUnderDeath_NoSignalStopsWatcherWithoutLeaking(t *testing.T) {
+	defer resetTestHooks()
+	codexCommand = "echo"
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{targetArg} }
+
+	jsonOutput := `{"type":"thread.started","thread_id":"death-session"}
+{"type":"item.completed","item":{"type":"agent_message","text":"done"}}`
+
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	defer logger.Close()
+
+	done := make(chan TaskResult, 1)
+	go func() { done <- runCodexTaskUnderDeath(TaskSpec{Task: jsonOutput}, 5, logger) }()
+
+	select {
+	case res := <-done:
+		if res.ExitCode != 0 {
+			t.Fatalf("unexpected exitCode %d, error=%s", res.ExitCode, res.Error)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("runCodexTaskUnderDeath did not return once the task finished on its own")
+	}
+}
+
 func TestSilentMode(t *testing.T) {
 	defer resetTestHooks()
 	jsonOutput := `{"type":"thread.started","thread_id":"silent-session"}
@@ -915,14 +1310,203 @@ func TestTopologicalSort_LargeGraph(t *testing.T) {
 	}
 }
 
+func TestReloadDAG_RejectsModifiedBodyWithoutReplace(t *testing.T) {
+	pending := map[string]TaskSpec{"b": {ID: "b", Task: "old"}}
+	newTasks := []TaskSpec{{ID: "b", Task: "new"}}
+	if _, _, err := reloadDAG(pending, nil, newTasks); err == nil || !strings.Contains(err.Error(), "body changed") {
+		t.Fatalf("expected a rejected-modification error, got %v", err)
+	}
+}
+
+func TestReloadDAG_AllowsModifiedBodyWithReplace(t *testing.T) {
+	pending := map[string]TaskSpec{"b": {ID: "b", Task: "old"}}
+	newTasks := []TaskSpec{{ID: "b", Task: "new", Reload: "replace"}}
+	merged, _, err := reloadDAG(pending, nil, newTasks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(merged) != 1 || merged[0].Task != "new" {
+		t.Fatalf("expected the replaced body to win, got %+v", merged)
+	}
+}
+
+func TestReloadDAG_ReportsRemovedPendingTasks(t *testing.T) {
+	pending := map[string]TaskSpec{"b": {ID: "b", Task: "x"}, "c": {ID: "c", Task: "y"}}
+	newTasks := []TaskSpec{{ID: "b", Task: "x"}}
+	merged, removed, err := reloadDAG(pending, nil, newTasks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(merged) != 1 || merged[0].ID != "b" {
+		t.Fatalf("expected only %q to survive, got %+v", "b", merged)
+	}
+	if len(removed) != 1 || removed[0] != "c" {
+		t.Fatalf("expected %q reported removed, got %+v", "c", removed)
+	}
+}
+
+func TestReloadDAG_SkipsCompletedTasksFromNewTasks(t *testing.T) {
+	completed := map[string]TaskResult{"a": {TaskID: "a", ExitCode: 0}}
+	newTasks := []TaskSpec{{ID: "a", Task: "done"}, {ID: "d", Task: "new"}}
+	merged, _, err := reloadDAG(nil, completed, newTasks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(merged) != 1 || merged[0].ID != "d" {
+		t.Fatalf("expected only the new task %q, got %+v", "d", merged)
+	}
+}
+
+// TestScheduler_ReloadAddsTaskDependingOnCompletedTask covers the request's
+// first reload scenario: a SIGHUP arriving once "a" has already finished
+// schedules a newly added task depending on it in the very next layer,
+// instead of waiting for a full new DAG.
+func TestScheduler_ReloadAddsTaskDependingOnCompletedTask(t *testing.T) {
+	orig := runCodexTaskFn
+	defer func() { runCodexTaskFn = orig }()
+
+	aStarted := make(chan struct{})
+	releaseA := make(chan struct{})
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		if task.ID == "a" {
+			close(aStarted)
+			<-releaseA
+		}
+		return TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	layers := [][]TaskSpec{{{ID: "a"}}, {{ID: "b", Dependencies: []string{"a"}}}}
+	sched := newScheduler(layers, 10)
+
+	go func() {
+		<-aStarted
+		sched.RequestReload([]TaskSpec{{ID: "a"}, {ID: "b", Dependencies: []string{"a"}}, {ID: "c", Dependencies: []string{"a"}}})
+		close(releaseA)
+	}()
+
+	results := sched.Run(context.Background())
+
+	byID := make(map[string]TaskResult, len(results))
+	for _, r := range results {
+		byID[r.TaskID] = r
+	}
+	if res, ok := byID["c"]; !ok || res.ExitCode != 0 {
+		t.Fatalf("expected newly-added task %q to run and succeed, got %+v (ok=%v)", "c", res, ok)
+	}
+}
+
+// TestScheduler_ReloadAddsTaskDependingOnRunningTask covers the request's
+// second reload scenario: a task added depending on one still in flight must
+// not run until that dependency finishes.
+func TestScheduler_ReloadAddsTaskDependingOnRunningTask(t *testing.T) {
+	orig := runCodexTaskFn
+	defer func() { runCodexTaskFn = orig }()
+
+	aStarted := make(chan struct{})
+	releaseA := make(chan struct{})
+	var dStartedAt time.Time
+	var mu sync.Mutex
+
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		switch task.ID {
+		case "a":
+			close(aStarted)
+			<-releaseA
+		case "d":
+			mu.Lock()
+			dStartedAt = time.Now()
+			mu.Unlock()
+		}
+		return TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	layers := [][]TaskSpec{{{ID: "a"}}}
+	sched := newScheduler(layers, 10)
+
+	var aFinishedAt time.Time
+	go func() {
+		<-aStarted
+		sched.RequestReload([]TaskSpec{{ID: "a"}, {ID: "d", Dependencies: []string{"a"}}})
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		aFinishedAt = time.Now()
+		mu.Unlock()
+		close(releaseA)
+	}()
+
+	results := sched.Run(context.Background())
+
+	byID := make(map[string]TaskResult, len(results))
+	for _, r := range results {
+		byID[r.TaskID] = r
+	}
+	if _, ok := byID["d"]; !ok {
+		t.Fatalf("expected newly-added task %q to eventually run, got %+v", "d", results)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if dStartedAt.Before(aFinishedAt) {
+		t.Fatalf("expected %q to wait for %q, but it started at %v before %q finished at %v", "d", "a", dStartedAt, "a", aFinishedAt)
+	}
+}
+
+// TestScheduler_ReloadCycleKeepsOldPlanAndWarns covers the request's third
+// reload scenario: a reload that would produce a cyclic DAG must leave the
+// existing plan untouched and report the rejection via warnFn.
+func TestScheduler_ReloadCycleKeepsOldPlanAndWarns(t *testing.T) {
+	orig := runCodexTaskFn
+	defer func() { runCodexTaskFn = orig }()
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		return TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	layers := [][]TaskSpec{{{ID: "a"}}, {{ID: "b", Dependencies: []string{"a"}}}}
+	sched := newScheduler(layers, 10)
+
+	var warnings []string
+	var mu sync.Mutex
+	sched.warnFn = func(msg string) {
+		mu.Lock()
+		warnings = append(warnings, msg)
+		mu.Unlock()
+	}
+
+	sched.RequestReload([]TaskSpec{
+		{ID: "a", Dependencies: []string{"b"}},
+		{ID: "b", Dependencies: []string{"a"}},
+	})
+
+	results := sched.Run(context.Background())
+
+	if len(results) != 2 {
+		t.Fatalf("expected the original 2-task plan to still run, got %d results: %+v", len(results), results)
+	}
+	for _, res := range results {
+		if res.ExitCode != 0 {
+			t.Fatalf("expected every original task to still succeed, got %+v", res)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(warnings) == 0 {
+		t.Fatalf("expected a warning reporting the rejected cyclic reload")
+	}
+}
+
 func TestExecuteConcurrent_ParallelExecution(t *testing.T) {
+	// Pin the worker pool instead of letting it default to runtime.NumCPU():
+	// on a single-core runner that default serializes every task and this
+	// test's parallelism assertion below fails.
+	t.Setenv("CODEX_PARALLEL_WORKERS", "10")
+
 	orig := runCodexTaskFn
 	defer func() { runCodexTaskFn = orig }()
 
 	var maxParallel int64
 	var current int64
 
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 		cur := atomic.AddInt64(&current, 1)
 		for {
 			prev := atomic.LoadInt64(&maxParallel)
@@ -937,7 +1521,7 @@ func TestExecuteConcurrent_ParallelExecution(t *testing.T) {
 
 	start := time.Now()
 	layers := [][]TaskSpec{{{ID: "a"}, {ID: "b"}, {ID: "c"}}}
-	results := executeConcurrent(layers, 10)
+	results := executeConcurrent(context.Background(), layers, 10)
 	elapsed := time.Since(start)
 
 	if len(results) != 3 {
@@ -958,7 +1542,7 @@ func TestExecuteConcurrent_LayerOrdering(t *testing.T) {
 	var mu sync.Mutex
 	var order []string
 
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 		mu.Lock()
 		order = append(order, task.ID)
 		mu.Unlock()
@@ -966,7 +1550,7 @@ func TestExecuteConcurrent_LayerOrdering(t *testing.T) {
 	}
 
 	layers := [][]TaskSpec{{{ID: "first-1"}, {ID: "first-2"}}, {{ID: "second"}}}
-	executeConcurrent(layers, 10)
+	executeConcurrent(context.Background(), layers, 10)
 
 	if len(order) != 3 || order[2] != "second" {
 		t.Fatalf("unexpected order: %+v", order)
@@ -977,7 +1561,7 @@ func TestExecuteConcurrent_ErrorIsolation(t *testing.T) {
 	orig := runCodexTaskFn
 	defer func() { runCodexTaskFn = orig }()
 
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 		if task.ID == "fail" {
 			return TaskResult{TaskID: task.ID, ExitCode: 2, Error: "boom"}
 		}
@@ -985,7 +1569,7 @@ func TestExecuteConcurrent_ErrorIsolation(t *testing.T) {
 	}
 
 	layers := [][]TaskSpec{{{ID: "ok"}, {ID: "fail"}}, {{ID: "after"}}}
-	results := executeConcurrent(layers, 10)
+	results := executeConcurrent(context.Background(), layers, 10)
 
 	if len(results) != 3 {
 		t.Fatalf("expected 3 results, got %d", len(results))
@@ -1010,11 +1594,11 @@ func TestExecuteConcurrent_PanicRecovered(t *testing.T) {
 	orig := runCodexTaskFn
 	defer func() { runCodexTaskFn = orig }()
 
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 		panic("boom")
 	}
 
-	results := executeConcurrent([][]TaskSpec{{{ID: "panic"}}}, 10)
+	results := executeConcurrent(context.Background(), [][]TaskSpec{{{ID: "panic"}}}, 10)
 	if len(results) != 1 || results[0].Error == "" || results[0].ExitCode == 0 {
 		t.Fatalf("panic should be captured, got %+v", results[0])
 	}
@@ -1024,17 +1608,381 @@ func TestExecuteConcurrent_LargeFanout(t *testing.T) {
 	orig := runCodexTaskFn
 	defer func() { runCodexTaskFn = orig }()
 
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult { return TaskResult{TaskID: task.ID} }
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult { return TaskResult{TaskID: task.ID} }
 	layer := make([]TaskSpec, 0, 1200)
 	for i := 0; i < 1200; i++ {
 		layer = append(layer, TaskSpec{ID: fmt.Sprintf("id-%d", i)})
 	}
-	results := executeConcurrent([][]TaskSpec{layer}, 10)
+	results := executeConcurrent(context.Background(), [][]TaskSpec{layer}, 10)
 	if len(results) != 1200 {
 		t.Fatalf("expected 1200 results, got %d", len(results))
 	}
 }
 
+func TestRunTaskWithRetry_SucceedsAfterRetryableFailures(t *testing.T) {
+	origInjector := failureInjector
+	defer func() { failureInjector = origInjector }()
+
+	var calls int32
+	failureInjector = func(attempt int, task TaskSpec) (bool, TaskResult) {
+		atomic.AddInt32(&calls, 1)
+		if attempt < 2 {
+			return true, TaskResult{TaskID: task.ID, ExitCode: 1, Error: "transient"}
+		}
+		return true, TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	task := TaskSpec{ID: "flaky", Retries: 3, RetryBackoff: time.Millisecond, RetryMaxBackoff: 5 * time.Millisecond}
+	result := runTaskWithRetry(context.Background(), task, 5)
+
+	if result.ExitCode != 0 {
+		t.Fatalf("expected eventual success, got %+v", result)
+	}
+	if len(result.Attempts) != 3 {
+		t.Fatalf("expected 3 attempts recorded, got %d: %+v", len(result.Attempts), result.Attempts)
+	}
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected 3 injector calls, got %d", calls)
+	}
+}
+
+func TestRunTaskWithRetry_NonRetryableExitCodeStopsImmediately(t *testing.T) {
+	origInjector := failureInjector
+	defer func() { failureInjector = origInjector }()
+
+	failureInjector = func(attempt int, task TaskSpec) (bool, TaskResult) {
+		return true, TaskResult{TaskID: task.ID, ExitCode: 130, Error: "cancelled"}
+	}
+
+	task := TaskSpec{ID: "cancelled", Retries: 5, RetryBackoff: time.Millisecond}
+	result := runTaskWithRetry(context.Background(), task, 5)
+
+	if len(result.Attempts) != 1 {
+		t.Fatalf("expected no retries for non-retryable exit code, got %+v", result.Attempts)
+	}
+}
+
+func TestRunTaskWithRetry_ExhaustsRetriesAndKeepsLastError(t *testing.T) {
+	origInjector := failureInjector
+	defer func() { failureInjector = origInjector }()
+
+	failureInjector = func(attempt int, task TaskSpec) (bool, TaskResult) {
+		return true, TaskResult{TaskID: task.ID, ExitCode: 1, Error: fmt.Sprintf("fail-%d", attempt)}
+	}
+
+	task := TaskSpec{ID: "always-fails", Retries: 2, RetryBackoff: time.Millisecond}
+	result := runTaskWithRetry(context.Background(), task, 5)
+
+	if len(result.Attempts) != 3 {
+		t.Fatalf("expected 1 initial attempt + 2 retries = 3, got %d: %+v", len(result.Attempts), result.Attempts)
+	}
+	if result.Error != "fail-2" {
+		t.Fatalf("expected last attempt's error retained, got %q", result.Error)
+	}
+}
+
+func TestRunTaskWithRetry_PanicCountsAsOneAttemptAndIsNonRetryableByDefault(t *testing.T) {
+	origInjector := failureInjector
+	defer func() { failureInjector = origInjector }()
+
+	var calls int32
+	failureInjector = func(attempt int, task TaskSpec) (bool, TaskResult) {
+		atomic.AddInt32(&calls, 1)
+		panic("boom")
+	}
+
+	task := TaskSpec{ID: "panics", Retries: 3, RetryBackoff: time.Millisecond}
+	result := runTaskWithRetry(context.Background(), task, 5)
+
+	if result.ExitCode != panicExitCode {
+		t.Fatalf("expected panicExitCode, got %+v", result)
+	}
+	if len(result.Attempts) != 1 {
+		t.Fatalf("expected a panic to be non-retryable by default (1 attempt), got %d: %+v", len(result.Attempts), result.Attempts)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected exactly 1 injector call, got %d", calls)
+	}
+}
+
+func TestRunTaskWithRetry_PanicRetriedWhenRetryOnIncludesSentinel(t *testing.T) {
+	origInjector := failureInjector
+	defer func() { failureInjector = origInjector }()
+
+	var calls int32
+	failureInjector = func(attempt int, task TaskSpec) (bool, TaskResult) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			panic("boom")
+		}
+		return true, TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	task := TaskSpec{ID: "panics-then-succeeds", Retries: 3, RetryBackoff: time.Millisecond, RetryOn: []int{panicExitCode}}
+	result := runTaskWithRetry(context.Background(), task, 5)
+
+	if result.ExitCode != 0 {
+		t.Fatalf("expected eventual success once RetryOn opts into retrying the panic sentinel, got %+v", result)
+	}
+	if len(result.Attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d: %+v", len(result.Attempts), result.Attempts)
+	}
+}
+
+func TestRunTaskWithRetry_RetryOnErrorSubstringOverridesNonRetryableCode(t *testing.T) {
+	origInjector := failureInjector
+	defer func() { failureInjector = origInjector }()
+
+	var calls int32
+	failureInjector = func(attempt int, task TaskSpec) (bool, TaskResult) {
+		atomic.AddInt32(&calls, 1)
+		if attempt < 1 {
+			return true, TaskResult{TaskID: task.ID, ExitCode: 124, Error: "stdin pipe closed: timeout"}
+		}
+		return true, TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	task := TaskSpec{
+		ID:           "timeout-retry",
+		Retries:      3,
+		RetryBackoff: time.Millisecond,
+		RetryOnError: []string{"timeout"},
+	}
+	result := runTaskWithRetry(context.Background(), task, 5)
+
+	if result.ExitCode != 0 {
+		t.Fatalf("expected retry_on_error substring match to retry past exit code 124, got %+v", result)
+	}
+	if len(result.Attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d: %+v", len(result.Attempts), result.Attempts)
+	}
+}
+
+func TestRunTaskWithRetry_BackoffMultiplierAndJitterAreHonored(t *testing.T) {
+	origInjector := failureInjector
+	defer func() { failureInjector = origInjector }()
+
+	failureInjector = func(attempt int, task TaskSpec) (bool, TaskResult) {
+		return true, TaskResult{TaskID: task.ID, ExitCode: 1, Error: "transient"}
+	}
+
+	task := TaskSpec{
+		ID:              "multiplier",
+		Retries:         3,
+		RetryBackoff:    10 * time.Millisecond,
+		RetryMaxBackoff: 11 * time.Millisecond,
+		RetryMultiplier: 10,
+		RetryJitter:     0.01,
+	}
+
+	start := time.Now()
+	runTaskWithRetry(context.Background(), task, 5)
+	elapsed := time.Since(start)
+
+	// With a x10 multiplier, every backoff after the first would blow past
+	// RetryMaxBackoff without it capping them back down to ~11ms each.
+	if elapsed > 100*time.Millisecond {
+		t.Fatalf("expected RetryMaxBackoff to cap the multiplied backoff, took %v", elapsed)
+	}
+}
+
+func TestGenerateFinalOutput_SurfacesAttemptCount(t *testing.T) {
+	out := generateFinalOutput([]TaskResult{
+		{TaskID: "retried", ExitCode: 0, Attempts: []AttemptRecord{{Attempt: 0, ExitCode: 1}, {Attempt: 1, ExitCode: 0}}},
+		{TaskID: "first-try", ExitCode: 0, Attempts: []AttemptRecord{{Attempt: 0, ExitCode: 0}}},
+	})
+
+	if !strings.Contains(out, "Attempts: 2") {
+		t.Fatalf("expected retried task's attempt count in output, got:\n%s", out)
+	}
+	if strings.Contains(out, "Attempts: 1") {
+		t.Fatalf("expected single-attempt tasks to omit the Attempts line, got:\n%s", out)
+	}
+}
+
+func TestRunTaskWithRetry_HonorsTimeoutDeadline(t *testing.T) {
+	origInjector := failureInjector
+	defer func() { failureInjector = origInjector }()
+
+	failureInjector = func(attempt int, task TaskSpec) (bool, TaskResult) {
+		return true, TaskResult{TaskID: task.ID, ExitCode: 1, Error: "transient"}
+	}
+
+	task := TaskSpec{ID: "slow-retry", Retries: 100, RetryBackoff: 50 * time.Millisecond, RetryMaxBackoff: 50 * time.Millisecond}
+
+	start := time.Now()
+	result := runTaskWithRetry(context.Background(), task, 0)
+	elapsed := time.Since(start)
+
+	if elapsed >= 50*time.Millisecond {
+		t.Fatalf("expected retry to stop at the timeout deadline quickly, took %v", elapsed)
+	}
+	if len(result.Attempts) != 1 {
+		t.Fatalf("expected a single attempt before the deadline cut retries short, got %+v", result.Attempts)
+	}
+}
+
+func TestRunTaskWithRetry_PerTaskTimeoutOverridesGlobal(t *testing.T) {
+	orig := runCodexTaskFn
+	defer func() { runCodexTaskFn = orig }()
+
+	var gotTimeout int
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		gotTimeout = timeout
+		return TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	task := TaskSpec{ID: "custom-timeout", Timeout: 7}
+	runTaskWithRetry(context.Background(), task, 300)
+
+	if gotTimeout != 7 {
+		t.Fatalf("expected task.Timeout to override the global timeout, got %d", gotTimeout)
+	}
+}
+
+func TestExecuteConcurrent_CancelMidRunReportsPartialAndCancelled(t *testing.T) {
+	orig := runCodexTaskFn
+	defer func() { runCodexTaskFn = orig }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		if task.ID == "blocked" {
+			<-ctx.Done()
+			return TaskResult{TaskID: task.ID, ExitCode: 130, Error: "cancelled"}
+		}
+		return TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	layers := [][]TaskSpec{
+		{{ID: "quick"}},
+		{{ID: "blocked"}},
+		{{ID: "never-scheduled"}},
+	}
+
+	done := make(chan []TaskResult, 1)
+	go func() { done <- executeConcurrent(ctx, layers, 10) }()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	var results []TaskResult
+	select {
+	case results = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("executeConcurrent did not return after cancellation")
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d: %+v", len(results), results)
+	}
+
+	byID := make(map[string]TaskResult, len(results))
+	for _, res := range results {
+		byID[res.TaskID] = res
+	}
+
+	if res := byID["quick"]; res.ExitCode != 0 {
+		t.Fatalf("quick task should have completed before cancellation, got %+v", res)
+	}
+	if res := byID["blocked"]; res.ExitCode != 130 || res.Error != "cancelled" {
+		t.Fatalf("in-flight task should report cancellation, got %+v", res)
+	}
+	if res := byID["never-scheduled"]; res.ExitCode != 130 || res.Error != "cancelled" {
+		t.Fatalf("unscheduled layer should be reported as cancelled, got %+v", res)
+	}
+}
+
+func TestScheduler_FailFastCancelsSleepingSiblingWithinGrace(t *testing.T) {
+	orig := runCodexTaskFn
+	defer func() { runCodexTaskFn = orig }()
+
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		switch task.ID {
+		case "fails-fast":
+			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "boom"}
+		case "sleeper":
+			<-ctx.Done()
+			return TaskResult{TaskID: task.ID, ExitCode: -1, Error: "cancelled"}
+		default:
+			return TaskResult{TaskID: task.ID, ExitCode: 0}
+		}
+	}
+
+	layers := [][]TaskSpec{{{ID: "fails-fast", RetryOn: []int{}}, {ID: "sleeper"}}}
+	sched := newScheduler(layers, 10).WithFailFast(true).WithShutdownGrace(500 * time.Millisecond)
+
+	done := make(chan []TaskResult, 1)
+	go func() { done <- sched.Run(context.Background()) }()
+
+	var results []TaskResult
+	select {
+	case results = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after fail-fast should have cancelled the sleeping sibling")
+	}
+
+	byID := make(map[string]TaskResult, len(results))
+	for _, res := range results {
+		byID[res.TaskID] = res
+	}
+
+	if res := byID["fails-fast"]; res.ExitCode != 1 || res.Error != "boom" {
+		t.Fatalf("fails-fast task result unexpected: %+v", res)
+	}
+	if res := byID["sleeper"]; res.ExitCode != failFastExitCode || res.Error != "cancelled" {
+		t.Fatalf("sleeper should be cancelled by fail-fast with ExitCode=%d, got %+v", failFastExitCode, res)
+	}
+}
+
+func TestScheduler_WithoutFailFastSleepingSiblingRunsToCompletion(t *testing.T) {
+	orig := runCodexTaskFn
+	defer func() { runCodexTaskFn = orig }()
+
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		switch task.ID {
+		case "fails-fast":
+			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "boom"}
+		case "sleeper":
+			return TaskResult{TaskID: task.ID, ExitCode: 0}
+		default:
+			return TaskResult{TaskID: task.ID, ExitCode: 0}
+		}
+	}
+
+	layers := [][]TaskSpec{{{ID: "fails-fast"}, {ID: "sleeper"}}}
+	results := newScheduler(layers, 10).Run(context.Background())
+
+	byID := make(map[string]TaskResult, len(results))
+	for _, res := range results {
+		byID[res.TaskID] = res
+	}
+
+	if res := byID["sleeper"]; res.ExitCode != 0 {
+		t.Fatalf("without --fail-fast the sibling should run to completion, got %+v", res)
+	}
+}
+
+func TestScheduler_WithShutdownGraceOverridesDefault(t *testing.T) {
+	sched := newScheduler(nil, 10)
+	if sched.shutdownGrace != shutdownGracePeriod {
+		t.Fatalf("expected default shutdownGrace %v, got %v", shutdownGracePeriod, sched.shutdownGrace)
+	}
+
+	sched.WithShutdownGrace(250 * time.Millisecond)
+	if sched.shutdownGrace != 250*time.Millisecond {
+		t.Fatalf("expected overridden shutdownGrace 250ms, got %v", sched.shutdownGrace)
+	}
+
+	// A non-positive value leaves the existing grace untouched rather than
+	// disabling it outright.
+	sched.WithShutdownGrace(0)
+	if sched.shutdownGrace != 250*time.Millisecond {
+		t.Fatalf("expected non-positive override to be ignored, got %v", sched.shutdownGrace)
+	}
+}
+
 func TestRun_ParallelFlag(t *testing.T) {
 	oldArgs := os.Args
 	defer func() { os.Args = oldArgs }()
@@ -1047,11 +1995,11 @@ test`
 	stdinReader = strings.NewReader(jsonInput)
 	defer func() { stdinReader = os.Stdin }()
 
-	runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult {
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
 		return TaskResult{TaskID: task.ID, ExitCode: 0, Message: "test output"}
 	}
 	defer func() {
-		runCodexTaskFn = func(task TaskSpec, timeout int) TaskResult { return runCodexTask(task, true, timeout) }
+		runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult { return runCodexTask(task, true, timeout) }
 	}()
 
 	exitCode := run()
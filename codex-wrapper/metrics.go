@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// histogramBuckets are the upper bounds (seconds) used for task_duration_seconds.
+var histogramBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Counter is a monotonic counter keyed by a single label value (e.g. status).
+type Counter struct {
+	mu     sync.Mutex
+	values map[string]*int64
+}
+
+func newCounter() *Counter { return &Counter{values: make(map[string]*int64)} }
+
+// Inc increments the counter for label by 1.
+func (c *Counter) Inc(label string) { c.Add(label, 1) }
+
+// Add increments the counter for label by delta.
+func (c *Counter) Add(label string, delta int64) {
+	c.mu.Lock()
+	v, ok := c.values[label]
+	if !ok {
+		v = new(int64)
+		c.values[label] = v
+	}
+	c.mu.Unlock()
+	atomic.AddInt64(v, delta)
+}
+
+func (c *Counter) snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]int64, len(c.values))
+	for k, v := range c.values {
+		out[k] = atomic.LoadInt64(v)
+	}
+	return out
+}
+
+// Gauge is a settable numeric value.
+type Gauge struct {
+	v atomic.Int64
+}
+
+// Set overwrites the gauge's current value.
+func (g *Gauge) Set(n int64) { g.v.Store(n) }
+
+// Inc increments the gauge by 1.
+func (g *Gauge) Inc() { g.v.Add(1) }
+
+// Dec decrements the gauge by 1.
+func (g *Gauge) Dec() { g.v.Add(-1) }
+
+// Value returns the gauge's current value.
+func (g *Gauge) Value() int64 { return g.v.Load() }
+
+type histogramData struct {
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+// Histogram tracks observations in fixed buckets, keyed by a single label
+// value (e.g. id_prefix).
+type Histogram struct {
+	mu   sync.Mutex
+	data map[string]*histogramData
+}
+
+func newHistogram() *Histogram { return &Histogram{data: make(map[string]*histogramData)} }
+
+// Observe records a single duration (in seconds) under label.
+func (h *Histogram) Observe(label string, seconds float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	d, ok := h.data[label]
+	if !ok {
+		d = &histogramData{counts: make([]int64, len(histogramBuckets))}
+		h.data[label] = d
+	}
+	for i, bound := range histogramBuckets {
+		if seconds <= bound {
+			d.counts[i]++
+		}
+	}
+	d.sum += seconds
+	d.count++
+}
+
+func (h *Histogram) snapshot() map[string]histogramData {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make(map[string]histogramData, len(h.data))
+	for k, v := range h.data {
+		countsCopy := make([]int64, len(v.counts))
+		copy(countsCopy, v.counts)
+		out[k] = histogramData{counts: countsCopy, sum: v.sum, count: v.count}
+	}
+	return out
+}
+
+// MetricsRegistry holds every metric exposed by the task runner.
+type MetricsRegistry struct {
+	TasksTotal    *Counter   // label: status (success|failure)
+	TasksSkipped  *Counter   // label: reason
+	TaskDuration  *Histogram // label: id_prefix
+	TasksInFlight Gauge
+	LayerDepth    Gauge
+}
+
+func newMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		TasksTotal:   newCounter(),
+		TasksSkipped: newCounter(),
+		TaskDuration: newHistogram(),
+	}
+}
+
+// metricsRegistry is the process-wide collector used by both the sequential
+// and --parallel execution paths, so they record identical metrics.
+var metricsRegistry = newMetricsRegistry()
+
+// Render writes the registry in Prometheus/OpenMetrics text exposition format.
+func (r *MetricsRegistry) Render(w io.Writer) error {
+	var buf bytes.Buffer
+
+	writeCounter(&buf, "tasks_total", "status", r.TasksTotal.snapshot())
+	writeCounter(&buf, "tasks_skipped_total", "reason", r.TasksSkipped.snapshot())
+
+	fmt.Fprintf(&buf, "# TYPE tasks_in_flight gauge\ntasks_in_flight %d\n", r.TasksInFlight.Value())
+	fmt.Fprintf(&buf, "# TYPE layer_depth gauge\nlayer_depth %d\n", r.LayerDepth.Value())
+
+	writeHistogram(&buf, "task_duration_seconds", "id_prefix", r.TaskDuration.snapshot())
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeCounter(buf *bytes.Buffer, name, labelName string, values map[string]int64) {
+	fmt.Fprintf(buf, "# TYPE %s counter\n", name)
+	for _, label := range sortedKeys(values) {
+		fmt.Fprintf(buf, "%s{%s=%q} %d\n", name, labelName, label, values[label])
+	}
+}
+
+func writeHistogram(buf *bytes.Buffer, name, labelName string, values map[string]histogramData) {
+	fmt.Fprintf(buf, "# TYPE %s histogram\n", name)
+	for _, label := range sortedHistogramKeys(values) {
+		d := values[label]
+		for i, bound := range histogramBuckets {
+			fmt.Fprintf(buf, "%s_bucket{%s=%q,le=%q} %d\n", name, labelName, label, strconv.FormatFloat(bound, 'g', -1, 64), d.counts[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s=%q,le=\"+Inf\"} %d\n", name, labelName, label, d.count)
+		fmt.Fprintf(buf, "%s_sum{%s=%q} %s\n", name, labelName, label, strconv.FormatFloat(d.sum, 'f', -1, 64))
+		fmt.Fprintf(buf, "%s_count{%s=%q} %d\n", name, labelName, label, d.count)
+	}
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedHistogramKeys(m map[string]histogramData) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// idPrefixLabel reduces a task ID to a low-cardinality label: the portion
+// before its first separator, or the whole ID if there is none.
+func idPrefixLabel(id string) string {
+	if id == "" {
+		return "unknown"
+	}
+	if i := strings.IndexAny(id, "-_:"); i > 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// startMetricsServer binds a /metrics endpoint on addr. The caller is
+// responsible for shutting it down (e.g. via Server.Shutdown).
+func startMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metricsRegistry.Render(w)
+	})
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "ERROR: metrics server stopped: %v\n", err)
+		}
+	}()
+	return srv
+}
+
+// StartPusher POSTs the registry's OpenMetrics payload to url on every tick
+// of interval, until ctx is cancelled. The returned channel is closed once
+// the pusher goroutine has fully stopped.
+func (r *MetricsRegistry) StartPusher(ctx context.Context, url string, interval time.Duration) <-chan struct{} {
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.push(url)
+			}
+		}
+	}()
+	return shutdownDone
+}
+
+func (r *MetricsRegistry) push(url string) {
+	var buf bytes.Buffer
+	if err := r.Render(&buf); err != nil {
+		return
+	}
+	resp, err := http.Post(url, "text/plain; version=0.0.4", &buf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: metrics push failed: %v\n", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// resolveMetricsAddr returns the --metrics-addr value from args if present,
+// otherwise METRICS_ADDR, otherwise "". Read with flagValue instead of
+// through newWrapperFlagSet/parseArgs because maybeStartMetricsServer runs
+// before any mode-specific flag set is parsed (it has to cover --daemon and
+// --parallel --worker alike, not just the single-task/--parallel/--pipeline
+// flag set).
+func resolveMetricsAddr(args []string) string {
+	if v, ok := flagValue(args, "--metrics-addr"); ok {
+		return v
+	}
+	return getEnv("METRICS_ADDR", "")
+}
+
+// resolvePushURL is resolveMetricsAddr for --push-url/PUSH_URL.
+func resolvePushURL(args []string) string {
+	if v, ok := flagValue(args, "--push-url"); ok {
+		return v
+	}
+	return getEnv("PUSH_URL", "")
+}
+
+// resolvePushInterval returns the --push-interval value from args (a Go
+// duration string, e.g. "15s") if present, otherwise PUSH_INTERVAL
+// (milliseconds, the legacy env-only unit) or a 15s default.
+func resolvePushInterval(args []string) time.Duration {
+	if raw, ok := flagValue(args, "--push-interval"); ok {
+		if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+			return d
+		}
+	}
+	raw := os.Getenv("PUSH_INTERVAL")
+	if raw == "" {
+		return 15 * time.Second
+	}
+	ms, err := strconv.Atoi(raw)
+	if err != nil || ms <= 0 {
+		return 15 * time.Second
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// maybeStartMetricsServer starts a /metrics HTTP server if --metrics-addr or
+// METRICS_ADDR is set.
+func maybeStartMetricsServer(args []string) *http.Server {
+	addr := resolveMetricsAddr(args)
+	if addr == "" {
+		return nil
+	}
+	return startMetricsServer(addr)
+}
+
+// maybeStartMetricsPusher starts the push-mode exporter if --push-url or
+// PUSH_URL is set.
+func maybeStartMetricsPusher(ctx context.Context, args []string) <-chan struct{} {
+	url := resolvePushURL(args)
+	if url == "" {
+		return nil
+	}
+	return metricsRegistry.StartPusher(ctx, url, resolvePushInterval(args))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsRegistry_Render(t *testing.T) {
+	r := newMetricsRegistry()
+	r.TasksTotal.Inc("success")
+	r.TasksTotal.Inc("success")
+	r.TasksTotal.Inc("failure")
+	r.TasksSkipped.Inc("failed_dependency")
+	r.TasksInFlight.Set(2)
+	r.LayerDepth.Set(3)
+	r.TaskDuration.Observe("build", 0.2)
+	r.TaskDuration.Observe("build", 5)
+
+	var buf strings.Builder
+	if err := r.Render(&buf); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`tasks_total{status="success"} 2`,
+		`tasks_total{status="failure"} 1`,
+		`tasks_skipped_total{reason="failed_dependency"} 1`,
+		"tasks_in_flight 2",
+		"layer_depth 3",
+		`task_duration_seconds_count{id_prefix="build"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("metrics output missing %q, got:\n%s", want, out)
+		}
+	}
+}
+
+// TestExecuteConcurrent_RecordsMetricsThroughTaskWrapper runs the existing DAG
+// fixture pattern through runCodexTaskWithContext (the shared instrumentation
+// point for both --parallel and the sequential path) and scrapes /metrics
+// afterwards, as both modes should record identical metrics.
+func TestExecuteConcurrent_RecordsMetricsThroughTaskWrapper(t *testing.T) {
+	defer resetTestHooks()
+	metricsRegistry = newMetricsRegistry()
+	defer func() { metricsRegistry = newMetricsRegistry() }()
+
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{} }
+
+	origFn := runCodexTaskFn
+	defer func() { runCodexTaskFn = origFn }()
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		if task.ID == "build-fail" {
+			codexCommand = "false"
+		} else {
+			codexCommand = "cat"
+			task.Task = `{"type":"item.completed","item":{"type":"agent_message","text":"done"}}`
+			task.UseStdin = true
+		}
+		return runCodexTaskWithContext(ctx, task, nil, false, true, timeout)
+	}
+
+	// RetryOn: []int{} opts this task out of the default retry-on-failure
+	// behavior so the assertions below see exactly one attempt.
+	layers := [][]TaskSpec{
+		{{ID: "build-fail", RetryOn: []int{}}},
+		{{ID: "build-blocked", Dependencies: []string{"build-fail"}}},
+	}
+	results := executeConcurrent(context.Background(), layers, 5)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		metricsRegistry.Render(w)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var sb strings.Builder
+	buf := make([]byte, 8192)
+	for {
+		n, err := resp.Body.Read(buf)
+		sb.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	out := sb.String()
+
+	if !strings.Contains(out, `tasks_total{status="failure"} 1`) {
+		t.Fatalf("expected one failed task recorded, got:\n%s", out)
+	}
+	if !strings.Contains(out, `tasks_skipped_total{reason="failed_dependency"} 1`) {
+		t.Fatalf("expected one skipped task recorded, got:\n%s", out)
+	}
+	if !strings.Contains(out, `task_duration_seconds_count{id_prefix="build"} 1`) {
+		t.Fatalf("expected duration observation under id_prefix=build, got:\n%s", out)
+	}
+}
+
+func TestResolveMetricsAddr_FlagOverridesEnv(t *testing.T) {
+	t.Setenv("METRICS_ADDR", ":9000")
+	if got := resolveMetricsAddr(nil); got != ":9000" {
+		t.Errorf("resolveMetricsAddr(nil) = %q, want %q (env fallback)", got, ":9000")
+	}
+	if got := resolveMetricsAddr([]string{"--metrics-addr", ":9090"}); got != ":9090" {
+		t.Errorf("resolveMetricsAddr(--metrics-addr) = %q, want %q (flag wins)", got, ":9090")
+	}
+}
+
+func TestResolvePushInterval_FlagOverridesLegacyEnvMilliseconds(t *testing.T) {
+	t.Setenv("PUSH_INTERVAL", "5000")
+	if got := resolvePushInterval(nil); got != 5*time.Second {
+		t.Errorf("resolvePushInterval(nil) = %v, want 5s (env fallback, milliseconds)", got)
+	}
+	if got := resolvePushInterval([]string{"--push-interval", "30s"}); got != 30*time.Second {
+		t.Errorf("resolvePushInterval(--push-interval) = %v, want 30s (flag wins)", got)
+	}
+}
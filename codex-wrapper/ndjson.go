@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// runParallelNDJSON runs cfg's DAG exactly as the human-readable --parallel
+// path does, but prints one TaskEvent per line to stdout as it happens
+// instead of waiting for every layer to finish and printing a summary. All
+// of it — the "received" events, the Scheduler's own queued/running/
+// succeeded/failed/skipped transitions, and the stdout_chunk/stderr_chunk
+// events runCodexTaskExec emits while a task runs — funnels through one
+// channel drained by a single goroutine, so concurrent tasks never
+// interleave partial lines on stdout.
+func runParallelNDJSON(ctx context.Context, cfg *ParallelConfig, timeoutSec int) int {
+	layers, err := topologicalSort(cfg.Tasks)
+	if err != nil {
+		encodeNDJSONError(err)
+		return 1
+	}
+
+	events := make(chan TaskEvent, 256)
+	var printer sync.WaitGroup
+	printer.Add(1)
+	go func() {
+		defer printer.Done()
+		enc := json.NewEncoder(os.Stdout)
+		for ev := range events {
+			_ = enc.Encode(ev)
+		}
+	}()
+
+	for _, task := range cfg.Tasks {
+		events <- TaskEvent{TaskID: task.ID, State: TaskReceived}
+	}
+
+	taskEventSink = func(ev TaskEvent) {
+		select {
+		case events <- ev:
+		default:
+		}
+	}
+	defer func() { taskEventSink = nil }()
+
+	sched := newScheduler(layers, timeoutSec)
+	sched.events = events
+
+	results := sched.Run(ctx)
+	close(events)
+	printer.Wait()
+
+	if logger := activeLogger(); logger != nil {
+		logger.Flush()
+	}
+
+	exitCode := 0
+	cancelled := false
+	for _, res := range results {
+		if res.ExitCode == 130 && res.Error == "cancelled" {
+			cancelled = true
+			continue
+		}
+		if res.ExitCode != 0 {
+			exitCode = res.ExitCode
+		}
+	}
+	if cancelled {
+		return 130
+	}
+	return exitCode
+}
+
+func encodeNDJSONError(err error) {
+	_ = json.NewEncoder(os.Stdout).Encode(map[string]string{"type": "error", "error": err.Error()})
+}
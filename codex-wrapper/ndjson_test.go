@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRunParallelNDJSON_EmitsLifecycleEvents(t *testing.T) {
+	defer resetTestHooks()
+	buildCodexArgsFn = func(cfg *Config, targetArg string) []string { return []string{} }
+	codexCommand = "cat"
+
+	cfg := &ParallelConfig{Tasks: []TaskSpec{
+		{ID: "build", Task: `{"type":"item.completed","item":{"type":"agent_message","text":"ok"}}`, UseStdin: true},
+	}}
+
+	out := captureStdoutPipe()
+	exitCode := runParallelNDJSON(context.Background(), cfg, 5)
+	restoreStdoutPipe(out)
+
+	if exitCode != 0 {
+		t.Fatalf("runParallelNDJSON() exit code = %d, want 0", exitCode)
+	}
+
+	var seenTypes []string
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var ev TaskEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		if ev.TaskID != "build" {
+			t.Errorf("event TaskID = %q, want %q", ev.TaskID, "build")
+		}
+		seenTypes = append(seenTypes, ev.State)
+	}
+
+	want := []string{TaskReceived, TaskQueued, TaskRunning, TaskSucceeded}
+	for _, w := range want {
+		found := false
+		for _, got := range seenTypes {
+			if got == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected a %q event among %v", w, seenTypes)
+		}
+	}
+}
+
+func TestChunkEventWriter_SplitsLinesAndEmits(t *testing.T) {
+	var events []TaskEvent
+	w := &chunkEventWriter{
+		taskID: "build",
+		state:  TaskStdoutChunk,
+		sink:   func(ev TaskEvent) { events = append(events, ev) },
+	}
+
+	if _, err := w.Write([]byte("line one\nline two\npart")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 emitted events before the trailing partial line, got %d", len(events))
+	}
+	if events[0].Chunk != "line one" || events[1].Chunk != "line two" {
+		t.Errorf("unexpected chunks: %+v", events)
+	}
+	if events[0].State != TaskStdoutChunk || events[0].TaskID != "build" {
+		t.Errorf("unexpected event metadata: %+v", events[0])
+	}
+}
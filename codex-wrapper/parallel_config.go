@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseParallelConfig parses --parallel's task configuration, sniffing the
+// format from the content itself (there's no filename to go on - stdin has
+// none) rather than requiring a flag: a leading '{' is JSON, a leading YAML
+// document marker or top-level "tasks:" key is YAML, and everything else is
+// the original ---TASK---/---CONTENT--- delimiter format. All three parsers
+// build the same ParallelConfig/TaskSpec shape and share one validation
+// pass (validateParallelConfig) so the duplicate-ID/missing-ID/missing-task/
+// empty-tasks rules can't drift between them.
+func parseParallelConfig(data []byte) (*ParallelConfig, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("parallel config is empty")
+	}
+
+	var cfg *ParallelConfig
+	var err error
+	switch detectConfigFormat(trimmed) {
+	case "json":
+		cfg, err = parseJSONConfig(trimmed)
+	case "yaml":
+		cfg, err = parseYAMLConfig(trimmed)
+	default:
+		cfg, err = parseDelimiterConfig(trimmed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateParallelConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// detectConfigFormat sniffs trimmed (already whitespace-trimmed, non-empty)
+// and returns "json", "yaml" or "delimiter".
+func detectConfigFormat(trimmed []byte) string {
+	if trimmed[0] == '{' {
+		return "json"
+	}
+
+	firstLine := strings.TrimSpace(string(bytes.SplitN(trimmed, []byte("\n"), 2)[0]))
+	if firstLine == "---" || strings.HasPrefix(firstLine, "tasks:") {
+		return "yaml"
+	}
+	return "delimiter"
+}
+
+// parseDelimiterConfig parses the original plain-text format: tasks
+// separated by a literal "---TASK---" line, each with a "key: value" meta
+// block followed by "---CONTENT---" and the task body.
+func parseDelimiterConfig(trimmed []byte) (*ParallelConfig, error) {
+	blocks := strings.Split(string(trimmed), "---TASK---")
+	var cfg ParallelConfig
+
+	for _, taskBlock := range blocks {
+		taskBlock = strings.TrimSpace(taskBlock)
+		if taskBlock == "" {
+			continue
+		}
+
+		parts := strings.SplitN(taskBlock, "---CONTENT---", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("task block missing ---CONTENT--- separator")
+		}
+
+		meta := strings.TrimSpace(parts[0])
+		content := strings.TrimSpace(parts[1])
+
+		task := TaskSpec{WorkDir: defaultWorkdir}
+		for _, line := range strings.Split(meta, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			kv := strings.SplitN(line, ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			key := strings.TrimSpace(kv[0])
+			value := strings.TrimSpace(kv[1])
+
+			switch key {
+			case "id":
+				task.ID = value
+			case "workdir":
+				task.WorkDir = value
+			case "session_id":
+				task.SessionID = value
+				task.Mode = "resume"
+			case "dependencies":
+				for _, dep := range strings.Split(value, ",") {
+					dep = strings.TrimSpace(dep)
+					if dep != "" {
+						task.Dependencies = append(task.Dependencies, dep)
+					}
+				}
+			case "reload":
+				task.Reload = value
+			}
+		}
+
+		task.Task = content
+		cfg.Tasks = append(cfg.Tasks, task)
+	}
+
+	return &cfg, nil
+}
+
+// parseJSONConfig parses the {"tasks": [...]} JSON shape directly into
+// ParallelConfig via its existing json tags, so timeout/env/retries etc.
+// are supported for free as TaskSpec grows new json-tagged fields.
+func parseJSONConfig(trimmed []byte) (*ParallelConfig, error) {
+	var cfg ParallelConfig
+	if err := json.Unmarshal(trimmed, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid JSON config: %w", err)
+	}
+	for i := range cfg.Tasks {
+		if cfg.Tasks[i].WorkDir == "" {
+			cfg.Tasks[i].WorkDir = defaultWorkdir
+		}
+		if cfg.Tasks[i].SessionID != "" {
+			cfg.Tasks[i].Mode = "resume"
+		}
+	}
+	return &cfg, nil
+}
+
+// validateParallelConfig applies the rules every parser must agree on:
+// every task needs a non-empty id, unique across the document, and a
+// non-empty body, and the document must declare at least one task.
+func validateParallelConfig(cfg *ParallelConfig) error {
+	if len(cfg.Tasks) == 0 {
+		return fmt.Errorf("no tasks found")
+	}
+
+	seen := make(map[string]struct{}, len(cfg.Tasks))
+	for _, task := range cfg.Tasks {
+		if task.ID == "" {
+			return fmt.Errorf("task missing id field")
+		}
+		if task.Task == "" {
+			return fmt.Errorf("task %q missing content", task.ID)
+		}
+		if _, exists := seen[task.ID]; exists {
+			return fmt.Errorf("duplicate task id: %s", task.ID)
+		}
+		seen[task.ID] = struct{}{}
+	}
+	return nil
+}
+
+// parseYAMLConfig parses a constrained subset of YAML - the exact task
+// schema this wrapper understands, not general YAML - since the repo has no
+// go.mod/vendored dependencies to pull in a real YAML library. It supports
+// scalar "key: value" fields, an inline or block "dependencies:" list, a
+// block "env:" mapping, and a "content: |" literal block for the task body.
+func parseYAMLConfig(trimmed []byte) (*ParallelConfig, error) {
+	lines := strings.Split(strings.ReplaceAll(string(trimmed), "\r\n", "\n"), "\n")
+
+	i := 0
+	for i < len(lines) {
+		t := strings.TrimSpace(lines[i])
+		if t == "" || t == "---" || strings.HasPrefix(t, "#") {
+			i++
+			continue
+		}
+		break
+	}
+	if i >= len(lines) || strings.TrimSpace(lines[i]) != "tasks:" {
+		return nil, fmt.Errorf("yaml config: expected a top-level \"tasks:\" key")
+	}
+	i++
+
+	var cfg ParallelConfig
+	for i < len(lines) {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+		itemIndent := yamlIndentOf(lines[i])
+		if itemIndent == 0 {
+			break
+		}
+		trimmedLine := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(trimmedLine, "- ") && trimmedLine != "-" {
+			return nil, fmt.Errorf("yaml config: expected a \"- \" task list item, got %q", trimmedLine)
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(trimmedLine, "-"))
+		i++
+
+		task := TaskSpec{WorkDir: defaultWorkdir}
+		fieldIndent := itemIndent + 2
+		if rest != "" {
+			consumed, err := parseYAMLTaskField(lines, i-1, fieldIndent, rest, &task, true)
+			if err != nil {
+				return nil, err
+			}
+			i += consumed
+		}
+
+		for i < len(lines) {
+			if strings.TrimSpace(lines[i]) == "" {
+				i++
+				continue
+			}
+			ind := yamlIndentOf(lines[i])
+			if ind < fieldIndent {
+				break
+			}
+			if ind > fieldIndent {
+				return nil, fmt.Errorf("yaml config: unexpected indentation in task %q", task.ID)
+			}
+			consumed, err := parseYAMLTaskField(lines, i, fieldIndent, strings.TrimSpace(lines[i]), &task, false)
+			if err != nil {
+				return nil, err
+			}
+			i += consumed
+		}
+
+		cfg.Tasks = append(cfg.Tasks, task)
+	}
+
+	return &cfg, nil
+}
+
+// parseYAMLTaskField consumes one "key: value" field starting at lines[at]
+// (whose already-trimmed text is given as line, since the inline-after-"- "
+// case has no line of its own to re-derive it from) and folds it into task.
+// It returns how many additional lines (beyond the one containing the key)
+// were consumed by a nested block construct (dependencies/env/content).
+func parseYAMLTaskField(lines []string, at int, fieldIndent int, line string, task *TaskSpec, inline bool) (int, error) {
+	key, val, ok := strings.Cut(line, ":")
+	if !ok {
+		return 0, fmt.Errorf("yaml config: malformed field %q", line)
+	}
+	key = strings.TrimSpace(key)
+	val = strings.TrimSpace(val)
+
+	consumed := 0
+	if !inline {
+		consumed = 1
+	}
+
+	switch key {
+	case "id":
+		task.ID = yamlUnquote(val)
+	case "workdir":
+		task.WorkDir = yamlUnquote(val)
+	case "session_id":
+		task.SessionID = yamlUnquote(val)
+		task.Mode = "resume"
+	case "reload":
+		task.Reload = yamlUnquote(val)
+	case "retry":
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, fmt.Errorf("yaml config: task %q retry must be an integer: %w", task.ID, err)
+		}
+		task.Retries = n
+	case "timeout":
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, fmt.Errorf("yaml config: task %q timeout must be an integer: %w", task.ID, err)
+		}
+		task.Timeout = n
+	case "retry_backoff_multiplier":
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return 0, fmt.Errorf("yaml config: task %q retry_backoff_multiplier must be a number: %w", task.ID, err)
+		}
+		task.RetryMultiplier = f
+	case "retry_jitter":
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return 0, fmt.Errorf("yaml config: task %q retry_jitter must be a number: %w", task.ID, err)
+		}
+		task.RetryJitter = f
+	case "weight":
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, fmt.Errorf("yaml config: task %q weight must be an integer: %w", task.ID, err)
+		}
+		task.Weight = n
+	case "dependencies":
+		deps, nested, err := parseYAMLList(lines, at+1, fieldIndent, val)
+		if err != nil {
+			return 0, err
+		}
+		task.Dependencies = deps
+		consumed += nested
+	case "retry_on_error":
+		substrs, nested, err := parseYAMLList(lines, at+1, fieldIndent, val)
+		if err != nil {
+			return 0, err
+		}
+		task.RetryOnError = substrs
+		consumed += nested
+	case "env":
+		env, nested, err := parseYAMLMap(lines, at+1, fieldIndent, val)
+		if err != nil {
+			return 0, err
+		}
+		task.Env = env
+		consumed += nested
+	case "content":
+		content, nested, err := parseYAMLBlockScalar(lines, at+1, fieldIndent, val)
+		if err != nil {
+			return 0, err
+		}
+		task.Task = content
+		consumed += nested
+	}
+
+	return consumed, nil
+}
+
+// yamlIndentOf returns the number of leading spaces on line.
+func yamlIndentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// yamlUnquote trims a "..." or '...' wrapper off a scalar, leaving bare
+// scalars untouched.
+func yamlUnquote(s string) string {
+	if len(s) >= 2 {
+		if (s[0] == '"' && s[len(s)-1] == '"') || (s[0] == '\'' && s[len(s)-1] == '\'') {
+			return s[1 : len(s)-1]
+		}
+	}
+	return s
+}
+
+// parseYAMLList reads a "dependencies:"-style list: either inline as
+// "[a, b, c]" (inline is non-empty, no lines consumed) or as "- item" lines
+// more indented than parentIndent (consumed counts those lines).
+func parseYAMLList(lines []string, from int, parentIndent int, inline string) ([]string, int, error) {
+	if inline != "" {
+		inline = strings.TrimPrefix(strings.TrimSuffix(inline, "]"), "[")
+		var items []string
+		for _, part := range strings.Split(inline, ",") {
+			part = yamlUnquote(strings.TrimSpace(part))
+			if part != "" {
+				items = append(items, part)
+			}
+		}
+		return items, 0, nil
+	}
+
+	var items []string
+	consumed := 0
+	for i := from; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			consumed++
+			continue
+		}
+		ind := yamlIndentOf(lines[i])
+		if ind <= parentIndent {
+			break
+		}
+		t := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(t, "- ") {
+			break
+		}
+		items = append(items, yamlUnquote(strings.TrimSpace(strings.TrimPrefix(t, "- "))))
+		consumed++
+	}
+	return items, consumed, nil
+}
+
+// parseYAMLMap reads an "env:"-style block mapping: "key: value" lines more
+// indented than parentIndent.
+func parseYAMLMap(lines []string, from int, parentIndent int, inline string) (map[string]string, int, error) {
+	if inline != "" && inline != "{}" {
+		return nil, 0, fmt.Errorf("yaml config: inline env maps are not supported, use a block mapping")
+	}
+
+	m := make(map[string]string)
+	consumed := 0
+	for i := from; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			consumed++
+			continue
+		}
+		ind := yamlIndentOf(lines[i])
+		if ind <= parentIndent {
+			break
+		}
+		k, v, ok := strings.Cut(strings.TrimSpace(lines[i]), ":")
+		if !ok {
+			break
+		}
+		m[strings.TrimSpace(k)] = yamlUnquote(strings.TrimSpace(v))
+		consumed++
+	}
+	if len(m) == 0 {
+		return nil, consumed, nil
+	}
+	return m, consumed, nil
+}
+
+// parseYAMLBlockScalar reads a "content: |" literal block: every line more
+// indented than parentIndent, up to (and not including) the next line at or
+// below parentIndent, with the block's own indent (set by its first line)
+// stripped from each. A non-"|" inline value is treated as a single-line
+// scalar instead.
+func parseYAMLBlockScalar(lines []string, from int, parentIndent int, inline string) (string, int, error) {
+	if inline != "" && inline != "|" && inline != "|-" {
+		return yamlUnquote(inline), 0, nil
+	}
+
+	var body []string
+	consumed := 0
+	blockIndent := -1
+	for i := from; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			if blockIndent >= 0 {
+				body = append(body, "")
+			}
+			consumed++
+			continue
+		}
+		ind := yamlIndentOf(lines[i])
+		if ind <= parentIndent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = ind
+		}
+		if ind < blockIndent {
+			break
+		}
+		body = append(body, lines[i][blockIndent:])
+		consumed++
+	}
+
+	return strings.TrimSpace(strings.Join(body, "\n")), consumed, nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parsePipelineYAML parses a constrained, Tekton-inspired pipeline manifest:
+// a top-level "tasks:" list of {name, runAfter, params, timeout, retries,
+// taskSpec: {content: |}} entries, plus an optional "finally:" list of the
+// same shape whose tasks always run after the main DAG regardless of
+// failures (see buildPipelineLayers and shouldSkipTask's Finally bypass).
+// It reuses the line/indent primitives parseYAMLConfig already built, rather
+// than a general YAML library, for the same no-go.mod reason documented
+// there - this understands exactly the subset above, nothing more.
+func parsePipelineYAML(data []byte) (mainTasks []TaskSpec, finallyTasks []TaskSpec, err error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil, fmt.Errorf("pipeline manifest is empty")
+	}
+	lines := strings.Split(strings.ReplaceAll(string(trimmed), "\r\n", "\n"), "\n")
+
+	sections := make(map[string][]TaskSpec)
+	i := 0
+	for i < len(lines) {
+		t := strings.TrimSpace(lines[i])
+		if t == "" || t == "---" || strings.HasPrefix(t, "#") {
+			i++
+			continue
+		}
+		if t == "tasks:" || t == "finally:" {
+			key := strings.TrimSuffix(t, ":")
+			items, consumed, perr := parsePipelineTaskList(lines, i+1)
+			if perr != nil {
+				return nil, nil, perr
+			}
+			sections[key] = items
+			i += 1 + consumed
+			continue
+		}
+		return nil, nil, fmt.Errorf("pipeline manifest: unexpected top-level key %q", t)
+	}
+
+	if len(sections["tasks"]) == 0 {
+		return nil, nil, fmt.Errorf("pipeline manifest: no tasks found under \"tasks:\"")
+	}
+
+	for i := range sections["finally"] {
+		sections["finally"][i].Finally = true
+	}
+	return sections["tasks"], sections["finally"], nil
+}
+
+// parsePipelineTaskList parses a "- name: ..." list (the body of "tasks:" or
+// "finally:"), mirroring parseYAMLConfig's task-list loop.
+func parsePipelineTaskList(lines []string, from int) ([]TaskSpec, int, error) {
+	var tasks []TaskSpec
+	consumed := 0
+	i := from
+
+	for i < len(lines) {
+		if strings.TrimSpace(lines[i]) == "" {
+			consumed++
+			i++
+			continue
+		}
+		itemIndent := yamlIndentOf(lines[i])
+		if itemIndent == 0 {
+			break
+		}
+		trimmedLine := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(trimmedLine, "- ") && trimmedLine != "-" {
+			break
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(trimmedLine, "-"))
+		consumed++
+		i++
+
+		task := TaskSpec{WorkDir: defaultWorkdir}
+		fieldIndent := itemIndent + 2
+		if rest != "" {
+			n, err := parsePipelineTaskField(lines, i-1, fieldIndent, rest, &task, true)
+			if err != nil {
+				return nil, 0, err
+			}
+			consumed += n
+			i += n
+		}
+
+		for i < len(lines) {
+			if strings.TrimSpace(lines[i]) == "" {
+				consumed++
+				i++
+				continue
+			}
+			ind := yamlIndentOf(lines[i])
+			if ind < fieldIndent {
+				break
+			}
+			if ind > fieldIndent {
+				return nil, 0, fmt.Errorf("pipeline manifest: unexpected indentation in task %q", task.ID)
+			}
+			n, err := parsePipelineTaskField(lines, i, fieldIndent, strings.TrimSpace(lines[i]), &task, false)
+			if err != nil {
+				return nil, 0, err
+			}
+			consumed += n
+			i += n
+		}
+
+		if task.ID == "" {
+			return nil, 0, fmt.Errorf("pipeline manifest: task missing name field")
+		}
+		if task.Task == "" {
+			return nil, 0, fmt.Errorf("pipeline manifest: task %q missing taskSpec.content", task.ID)
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, consumed, nil
+}
+
+// parsePipelineTaskField consumes one field of a pipeline task item,
+// translating Tekton-ish names onto TaskSpec: "name" -> ID, "runAfter" ->
+// Dependencies, "params" -> Env (the most natural execution-time home for
+// per-task parameters), "timeout"/"retries" as integers, and a nested
+// "taskSpec: {content: |...}" block as Task.
+func parsePipelineTaskField(lines []string, at int, fieldIndent int, line string, task *TaskSpec, inline bool) (int, error) {
+	key, val, ok := strings.Cut(line, ":")
+	if !ok {
+		return 0, fmt.Errorf("pipeline manifest: malformed field %q", line)
+	}
+	key = strings.TrimSpace(key)
+	val = strings.TrimSpace(val)
+
+	consumed := 0
+	if !inline {
+		consumed = 1
+	}
+
+	switch key {
+	case "name":
+		task.ID = yamlUnquote(val)
+	case "timeout":
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, fmt.Errorf("pipeline manifest: task %q timeout must be an integer: %w", task.ID, err)
+		}
+		task.Timeout = n
+	case "retries":
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, fmt.Errorf("pipeline manifest: task %q retries must be an integer: %w", task.ID, err)
+		}
+		task.Retries = n
+	case "runAfter":
+		deps, nested, err := parseYAMLList(lines, at+1, fieldIndent, val)
+		if err != nil {
+			return 0, err
+		}
+		task.Dependencies = deps
+		consumed += nested
+	case "params":
+		params, nested, err := parseYAMLMap(lines, at+1, fieldIndent, val)
+		if err != nil {
+			return 0, err
+		}
+		task.Env = params
+		consumed += nested
+	case "taskSpec":
+		content, nested, err := parseTaskSpecBlock(lines, at+1, fieldIndent)
+		if err != nil {
+			return 0, err
+		}
+		task.Task = content
+		consumed += nested
+	}
+
+	return consumed, nil
+}
+
+// parseTaskSpecBlock parses a nested "taskSpec:" mapping - today just its
+// "content: |" field, the only taskSpec key this wrapper understands. Other
+// fields (e.g. Tekton's "steps:") are tolerated and skipped.
+func parseTaskSpecBlock(lines []string, from int, parentIndent int) (string, int, error) {
+	i := from
+	consumed := 0
+	blockIndent := -1
+	var content string
+	haveContent := false
+
+	for i < len(lines) {
+		if strings.TrimSpace(lines[i]) == "" {
+			consumed++
+			i++
+			continue
+		}
+		ind := yamlIndentOf(lines[i])
+		if ind <= parentIndent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = ind
+		}
+		if ind != blockIndent {
+			return "", 0, fmt.Errorf("pipeline manifest: unexpected indentation inside taskSpec")
+		}
+
+		k, v, ok := strings.Cut(strings.TrimSpace(lines[i]), ":")
+		if !ok {
+			return "", 0, fmt.Errorf("pipeline manifest: malformed taskSpec field %q", lines[i])
+		}
+		key := strings.TrimSpace(k)
+		val := strings.TrimSpace(v)
+
+		if key == "content" {
+			c, nested, err := parseYAMLBlockScalar(lines, i+1, blockIndent, val)
+			if err != nil {
+				return "", 0, err
+			}
+			content = c
+			haveContent = true
+			consumed += 1 + nested
+			i += 1 + nested
+			continue
+		}
+
+		consumed++
+		i++
+	}
+
+	if !haveContent {
+		return "", consumed, fmt.Errorf("taskSpec missing content field")
+	}
+	return content, consumed, nil
+}
+
+// buildPipelineLayers topologically sorts mainTasks and finallyTasks
+// together - so an unknown runAfter reference or a runAfter cycle surfaces
+// topologicalSort's existing "dependency ... not found"/"cycle detected"
+// errors regardless of which list it's in - then pulls every finally task
+// out of whatever layer it landed in and appends them as one trailing layer,
+// so they always run last, after the entire main DAG, independent of the
+// main tasks' own outcomes.
+func buildPipelineLayers(mainTasks, finallyTasks []TaskSpec) ([][]TaskSpec, error) {
+	all := make([]TaskSpec, 0, len(mainTasks)+len(finallyTasks))
+	all = append(all, mainTasks...)
+	all = append(all, finallyTasks...)
+
+	rawLayers, err := topologicalSort(all)
+	if err != nil {
+		return nil, err
+	}
+
+	finallySet := make(map[string]bool, len(finallyTasks))
+	for _, t := range finallyTasks {
+		finallySet[t.ID] = true
+	}
+
+	var layers [][]TaskSpec
+	var trailing []TaskSpec
+	for _, layer := range rawLayers {
+		var kept []TaskSpec
+		for _, t := range layer {
+			if finallySet[t.ID] {
+				trailing = append(trailing, t)
+			} else {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) > 0 {
+			layers = append(layers, kept)
+		}
+	}
+	if len(trailing) > 0 {
+		layers = append(layers, trailing)
+	}
+	return layers, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// installConfigReloadHandler wires SIGHUP, separately from the SIGINT/
+// SIGTERM shutdown path, to a re-read of configPath: on signal it re-parses
+// the file and hands the new task list to sched.RequestReload, which merges
+// it in at the next layer boundary without touching already-running
+// children. Parse or read failures are reported via sched.warnFn and leave
+// the in-flight plan untouched.
+func installConfigReloadHandler(sched *Scheduler, configPath string) (stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				data, err := os.ReadFile(configPath)
+				if err != nil {
+					sched.warnFn("SIGHUP reload: failed to read " + configPath + ": " + err.Error())
+					continue
+				}
+				cfg, err := parseParallelConfig(data)
+				if err != nil {
+					sched.warnFn("SIGHUP reload: failed to parse " + configPath + ": " + err.Error())
+					continue
+				}
+				sched.RequestReload(cfg.Tasks)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
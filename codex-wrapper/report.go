@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// taskReportEntry is one line of a --report JSONL file: a single task's
+// full outcome, enough to reconstruct the run without re-reading logs.
+type taskReportEntry struct {
+	TaskID     string   `json:"task_id"`
+	Layer      int      `json:"layer"`
+	Deps       []string `json:"deps,omitempty"`
+	Status     string   `json:"status"`
+	Attempts   int      `json:"attempts"`
+	ExitCode   int      `json:"exit_code"`
+	StartedAt  string   `json:"started_at,omitempty"`
+	EndedAt    string   `json:"ended_at,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+	SessionID  string   `json:"session_id,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// reportSummaryEntry is the trailing line of a --report JSONL file,
+// distinguished from a taskReportEntry by its "summary" key.
+type reportSummaryEntry struct {
+	Summary    bool  `json:"summary"`
+	Total      int   `json:"total"`
+	Succeeded  int   `json:"succeeded"`
+	Failed     int   `json:"failed"`
+	Skipped    int   `json:"skipped"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// taskStatus classifies a finished TaskResult for reporting purposes.
+func taskStatus(res TaskResult) string {
+	if res.Skipped {
+		return "skipped"
+	}
+	if res.ExitCode == 0 && res.Error == "" {
+		return "succeeded"
+	}
+	return "failed"
+}
+
+// taskLayerIndex maps every task ID in layers to its layer number, and to
+// its declared dependencies, for writeReport to join against results.
+func taskLayerIndex(layers [][]TaskSpec) (layerOf map[string]int, depsOf map[string][]string) {
+	layerOf = make(map[string]int)
+	depsOf = make(map[string][]string)
+	for idx, layer := range layers {
+		for _, task := range layer {
+			layerOf[task.ID] = idx
+			depsOf[task.ID] = task.Dependencies
+		}
+	}
+	return layerOf, depsOf
+}
+
+// writeRunArtifacts writes cfg.ReportPath/cfg.MetricsPath, if set, once a
+// --parallel or --pipeline run has finished - regardless of whether the run
+// itself succeeded, so a failed DAG is just as inspectable as a clean one.
+// A write failure is reported but never changes the run's own exit code.
+func writeRunArtifacts(cfg *Config, results []TaskResult, layers [][]TaskSpec) {
+	if cfg.ReportPath != "" {
+		if err := writeReport(cfg.ReportPath, results, layers); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to write --report %s: %v\n", cfg.ReportPath, err)
+		}
+	}
+	if cfg.MetricsPath != "" {
+		if err := writeMetricsFile(cfg.MetricsPath, results, layers); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to write --metrics %s: %v\n", cfg.MetricsPath, err)
+		}
+	}
+}
+
+// writeReport renders results as a --report JSONL file: one taskReportEntry
+// per task (in the same order as results), followed by one
+// reportSummaryEntry. layers supplies each task's layer index and
+// dependencies, mirroring the DAG structure the task list doesn't carry on
+// its own TaskResult.
+func writeReport(path string, results []TaskResult, layers [][]TaskSpec) error {
+	layerOf, depsOf := taskLayerIndex(layers)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	summary := reportSummaryEntry{Summary: true}
+	for _, res := range results {
+		attempts := len(res.Attempts)
+		if attempts == 0 {
+			attempts = 1
+		}
+		status := taskStatus(res)
+
+		entry := taskReportEntry{
+			TaskID:     res.TaskID,
+			Layer:      layerOf[res.TaskID],
+			Deps:       depsOf[res.TaskID],
+			Status:     status,
+			Attempts:   attempts,
+			ExitCode:   res.ExitCode,
+			DurationMs: res.DurationMs,
+			SessionID:  res.SessionID,
+			Error:      res.Error,
+		}
+		if !res.StartedAt.IsZero() {
+			entry.StartedAt = res.StartedAt.Format(time.RFC3339Nano)
+		}
+		if !res.EndedAt.IsZero() {
+			entry.EndedAt = res.EndedAt.Format(time.RFC3339Nano)
+		}
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("report: encode %s: %w", res.TaskID, err)
+		}
+
+		summary.Total++
+		summary.DurationMs += res.DurationMs
+		switch status {
+		case "succeeded":
+			summary.Succeeded++
+		case "skipped":
+			summary.Skipped++
+		default:
+			summary.Failed++
+		}
+	}
+	if err := enc.Encode(summary); err != nil {
+		return fmt.Errorf("report: encode summary: %w", err)
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// writeMetricsFile renders results as a standalone Prometheus text
+// exposition file (as opposed to metricsRegistry.Render's live /metrics
+// endpoint, which aggregates across the whole process rather than one run).
+// Metric names are codex_-prefixed to read unambiguously once scraped
+// alongside other exporters. A layer's duration is approximated as its
+// slowest task's duration, since layers run concurrently and results/layers
+// alone don't carry the Scheduler's own layer-drain timing.
+func writeMetricsFile(path string, results []TaskResult, layers [][]TaskSpec) error {
+	var buf bytes.Buffer
+
+	byTaskID := make(map[string]TaskResult, len(results))
+	statusCounts := newCounter()
+	duration := newHistogram()
+	retries := newCounter()
+	for _, res := range results {
+		byTaskID[res.TaskID] = res
+		statusCounts.Inc(taskStatus(res))
+		duration.Observe(idPrefixLabel(res.TaskID), float64(res.DurationMs)/1000)
+		if extra := len(res.Attempts) - 1; extra > 0 {
+			retries.Add(idPrefixLabel(res.TaskID), int64(extra))
+		}
+	}
+
+	layerDuration := newHistogram()
+	for idx, layer := range layers {
+		var slowestMs int64
+		for _, task := range layer {
+			if res, ok := byTaskID[task.ID]; ok && res.DurationMs > slowestMs {
+				slowestMs = res.DurationMs
+			}
+		}
+		layerDuration.Observe(fmt.Sprintf("%d", idx), float64(slowestMs)/1000)
+	}
+
+	writeCounter(&buf, "codex_tasks_total", "status", statusCounts.snapshot())
+	writeHistogram(&buf, "codex_task_duration_seconds", "id_prefix", duration.snapshot())
+	writeCounter(&buf, "codex_task_retries_total", "id_prefix", retries.snapshot())
+	writeHistogram(&buf, "codex_layer_duration_seconds", "layer", layerDuration.snapshot())
+
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
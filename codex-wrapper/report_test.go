@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteReport_ValidCompleteJSONL(t *testing.T) {
+	defer resetTestHooks()
+
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		if task.ID == "build-fail" {
+			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "boom"}
+		}
+		return TaskResult{TaskID: task.ID, ExitCode: 0, SessionID: "sess-1"}
+	}
+
+	layers := [][]TaskSpec{
+		{{ID: "build-fail", RetryOn: []int{}}},
+		{{ID: "build-blocked", Dependencies: []string{"build-fail"}}},
+	}
+	results := newScheduler(layers, 5).Run(context.Background())
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.jsonl")
+	if err := writeReport(path, results, layers); err != nil {
+		t.Fatalf("writeReport() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+
+	var entries []taskReportEntry
+	var summary *reportSummaryEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(line, &probe); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		if _, ok := probe["summary"]; ok {
+			var s reportSummaryEntry
+			if err := json.Unmarshal(line, &s); err != nil {
+				t.Fatalf("invalid summary line: %v", err)
+			}
+			summary = &s
+			continue
+		}
+		var e taskReportEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			t.Fatalf("invalid task line: %v", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 task entries, got %d", len(entries))
+	}
+	if summary == nil {
+		t.Fatal("expected a trailing summary line")
+	}
+	if summary.Total != 2 || summary.Succeeded != 0 || summary.Failed != 1 || summary.Skipped != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	byID := make(map[string]taskReportEntry, len(entries))
+	for _, e := range entries {
+		byID[e.TaskID] = e
+	}
+
+	failed := byID["build-fail"]
+	if failed.Layer != 0 || failed.Status != "failed" || failed.ExitCode != 1 {
+		t.Fatalf("unexpected report entry for build-fail: %+v", failed)
+	}
+	if failed.StartedAt == "" || failed.EndedAt == "" {
+		t.Fatalf("expected started_at/ended_at to be populated, got %+v", failed)
+	}
+
+	skipped := byID["build-blocked"]
+	if skipped.Layer != 1 || skipped.Status != "skipped" || len(skipped.Deps) != 1 || skipped.Deps[0] != "build-fail" {
+		t.Fatalf("unexpected report entry for build-blocked: %+v", skipped)
+	}
+}
+
+func TestWriteReport_WritesEvenOnNonZeroExit(t *testing.T) {
+	defer resetTestHooks()
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "boom"}
+	}
+
+	layers := [][]TaskSpec{{{ID: "fails", RetryOn: []int{}}}}
+	results := newScheduler(layers, 5).Run(context.Background())
+
+	dir := t.TempDir()
+	reportPath := filepath.Join(dir, "report.jsonl")
+	metricsPath := filepath.Join(dir, "metrics.txt")
+	cfg := &Config{ReportPath: reportPath, MetricsPath: metricsPath}
+	writeRunArtifacts(cfg, results, layers)
+
+	if _, err := os.Stat(reportPath); err != nil {
+		t.Fatalf("expected report to be written despite failure: %v", err)
+	}
+	if _, err := os.Stat(metricsPath); err != nil {
+		t.Fatalf("expected metrics to be written despite failure: %v", err)
+	}
+}
+
+func TestWriteMetricsFile_ExpositionFormatAndBucketCounts(t *testing.T) {
+	defer resetTestHooks()
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		if task.ID == "two" {
+			return TaskResult{TaskID: task.ID, ExitCode: 1, Error: "boom"}
+		}
+		return TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	layers := [][]TaskSpec{{{ID: "one", RetryOn: []int{}}, {ID: "two", RetryOn: []int{}}}}
+	results := newScheduler(layers, 5).Run(context.Background())
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "metrics.txt")
+	if err := writeMetricsFile(path, results, layers); err != nil {
+		t.Fatalf("writeMetricsFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read metrics file: %v", err)
+	}
+	out := string(data)
+
+	totalBuckets := 0
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "codex_task_duration_seconds_bucket") && strings.Contains(line, `le="+Inf"`) {
+			totalBuckets++
+		}
+	}
+	if totalBuckets != 2 {
+		t.Fatalf("expected one +Inf bucket line per task (2), got %d in:\n%s", totalBuckets, out)
+	}
+
+	for _, want := range []string{
+		`codex_tasks_total{status="succeeded"} 1`,
+		`codex_tasks_total{status="failed"} 1`,
+		"codex_layer_duration_seconds",
+		"codex_task_retries_total",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("metrics file missing %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// defaultShutdownGrace is how long awaitGracefulShutdown waits for
+// registered cleanup hooks to finish before giving up, unless overridden by
+// CODEX_SHUTDOWN_GRACE.
+const defaultShutdownGrace = 10 * time.Second
+
+// shutdownGrace reads CODEX_SHUTDOWN_GRACE (seconds) and returns the
+// matching duration, falling back to defaultShutdownGrace when unset or
+// invalid.
+func shutdownGrace() time.Duration {
+	raw := os.Getenv("CODEX_SHUTDOWN_GRACE")
+	if raw == "" {
+		return defaultShutdownGrace
+	}
+	secs, err := strconv.Atoi(raw)
+	if err != nil || secs <= 0 {
+		return defaultShutdownGrace
+	}
+	return time.Duration(secs) * time.Second
+}
+
+var (
+	cleanupHooksMu sync.Mutex
+	cleanupHooks   []func(context.Context) error
+)
+
+// RegisterCleanup adds fn to the set of hooks run by awaitGracefulShutdown,
+// in reverse registration order (last registered, first run, Death-style),
+// each under the same grace-period deadline.
+func RegisterCleanup(fn func(context.Context) error) {
+	cleanupHooksMu.Lock()
+	defer cleanupHooksMu.Unlock()
+	cleanupHooks = append(cleanupHooks, fn)
+}
+
+// resetCleanupHooks clears every registered hook. Test-only.
+func resetCleanupHooks() {
+	cleanupHooksMu.Lock()
+	defer cleanupHooksMu.Unlock()
+	cleanupHooks = nil
+}
+
+// runCleanupHooks runs every hook registered via RegisterCleanup in reverse
+// order, logging (rather than aborting on) individual failures so one bad
+// hook doesn't block the rest.
+func runCleanupHooks(ctx context.Context) {
+	cleanupHooksMu.Lock()
+	hooks := append([]func(context.Context) error(nil), cleanupHooks...)
+	cleanupHooksMu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			logWarn(fmt.Sprintf("shutdown cleanup hook failed: %v", err))
+		}
+	}
+}
+
+// signalExitCode maps a terminating signal to the Unix convention (128+n)
+// process supervisors expect: 130 for SIGINT, 143 for SIGTERM. SIGHUP is
+// treated as a graceful-shutdown request too (same as SIGTERM) rather than
+// the default terminal-hangup behavior.
+func signalExitCode(sig os.Signal) int {
+	switch sig {
+	case syscall.SIGINT:
+		return 130
+	case syscall.SIGTERM, syscall.SIGHUP:
+		return 143
+	default:
+		return 1
+	}
+}
+
+// newShutdownContext returns a context derived from parent that is
+// cancelled on the first SIGINT, SIGTERM or SIGHUP, the func to call
+// afterwards for the matching process exit code (0 if no signal fired), and
+// a stop func that must be deferred to release the signal handler.
+func newShutdownContext(parent context.Context) (ctx context.Context, exitCode func() int, stop func()) {
+	return newShutdownContextWithSignals(parent, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+}
+
+// newShutdownContextWithSignals is newShutdownContext, but cancelling only on
+// sigs. --parallel --config passes SIGINT/SIGTERM alone, since its own
+// installConfigReloadHandler owns SIGHUP there and a live reload must not
+// also tear down the run.
+func newShutdownContextWithSignals(parent context.Context, sigs ...os.Signal) (ctx context.Context, exitCode func() int, stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, sigs...)
+
+	ctx, cancel := context.WithCancel(parent)
+	var code atomic.Int32
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-sigCh:
+			code.Store(int32(signalExitCode(sig)))
+			cancel()
+		case <-done:
+		}
+	}()
+
+	return ctx, func() int { return int(code.Load()) }, func() {
+		signal.Stop(sigCh)
+		close(done)
+		cancel()
+	}
+}
+
+// awaitGracefulShutdown runs every registered cleanup hook under
+// shutdownGrace's budget if runCtx was cancelled by a signal (via
+// newShutdownContext); it is a no-op on ordinary completion.
+func awaitGracefulShutdown(runCtx context.Context) {
+	if runCtx.Err() == nil {
+		return
+	}
+	hookCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace())
+	defer cancel()
+	runCleanupHooks(hookCtx)
+}
+
+// Death coordinates the single-task wrapper's shutdown the same way the
+// seelog death package does: register the signals that mean "die" once via
+// NewDeath, then block in WaitForDeath until one arrives, at which point
+// every closer (kill the child, drain the logger) runs concurrently under
+// one shared timeout instead of each owning its own ad-hoc deadline.
+type Death struct {
+	timeout time.Duration
+	sigCh   chan os.Signal
+	stopped chan struct{}
+}
+
+// NewDeath returns a Death that fires on the first delivery of any of sigs,
+// giving each closer in WaitForDeath up to timeout to finish.
+func NewDeath(timeout time.Duration, sigs ...os.Signal) *Death {
+	d := &Death{timeout: timeout, sigCh: make(chan os.Signal, 1), stopped: make(chan struct{})}
+	signal.Notify(d.sigCh, sigs...)
+	return d
+}
+
+// Stop unregisters the signal handler and unblocks a WaitForDeath call that
+// would otherwise wait forever for a signal that's never coming - the
+// single-task run() path calls this once its task has already finished on
+// its own, so the watcher goroutine racing against WaitForDeath doesn't leak.
+func (d *Death) Stop() {
+	signal.Stop(d.sigCh)
+	close(d.stopped)
+}
+
+// WaitForDeath blocks until a registered signal arrives (returning early,
+// without touching cmd or logger, if Stop is called first), then
+// concurrently terminates cmd (SIGTERM, escalating to SIGKILL, blocking
+// until the process actually exits) and drains logger, both bounded by the
+// shared timeout passed to NewDeath, and returns once both are done. exited
+// must close once cmd has been reaped (e.g. a cmd.Wait() goroutine the
+// caller already owns) - WaitForDeath never calls cmd.Wait() itself, since
+// *exec.Cmd forbids waiting on the same process twice.
+func (d *Death) WaitForDeath(logger *Logger, cmd *exec.Cmd, exited <-chan struct{}) {
+	select {
+	case <-d.sigCh:
+	case <-d.stopped:
+		return
+	}
+	signal.Stop(d.sigCh)
+
+	deadline := time.Now().Add(d.timeout)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		timer := terminateProcess(cmd, time.Until(deadline))
+		if timer == nil {
+			return
+		}
+		if exited != nil {
+			<-exited
+		}
+		timer.Stop()
+	}()
+
+	go func() {
+		defer wg.Done()
+		if logger == nil {
+			return
+		}
+		done := make(chan struct{})
+		go func() {
+			logger.Close()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Until(deadline)):
+		}
+	}()
+
+	wg.Wait()
+}
+
+// deathTarget bundles the live *exec.Cmd Death should terminate with a
+// channel that closes once the process has actually been reaped, so
+// WaitForDeath can block on real exit instead of declaring victory the
+// instant the kill signal is sent.
+type deathTarget struct {
+	cmd    *exec.Cmd
+	exited <-chan struct{}
+}
+
+// deathCmdSinkKey is the context.Value key contextWithDeathSink stores its
+// channel under; unexported so only this file's helpers can read or write
+// it.
+type deathCmdSinkKey struct{}
+
+// contextWithDeathSink returns a context that makes runCodexTaskExec send a
+// deathTarget on sink the moment the child process starts, instead of the
+// default ad-hoc signal.NotifyContext/terminateProcess handling. run()'s
+// single-task path is the only caller: it's the one place a Death can own
+// an entire process's shutdown (kill the child, drain the logger, one
+// shared deadline) without risking a signal-handler/goroutine leaked per
+// task the way installing one inside every --parallel/--pipeline task
+// would. Every other caller passes a context without this value, and
+// runCodexTaskExec's existing per-call signal handling covers them exactly
+// as before.
+func contextWithDeathSink(ctx context.Context, sink chan<- deathTarget) context.Context {
+	return context.WithValue(ctx, deathCmdSinkKey{}, sink)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownGrace(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":    defaultShutdownGrace,
+		"0":   defaultShutdownGrace,
+		"-5":  defaultShutdownGrace,
+		"abc": defaultShutdownGrace,
+		"30":  30 * time.Second,
+		"1":   time.Second,
+	}
+	for env, want := range cases {
+		t.Setenv("CODEX_SHUTDOWN_GRACE", env)
+		if got := shutdownGrace(); got != want {
+			t.Errorf("shutdownGrace() with CODEX_SHUTDOWN_GRACE=%q = %v, want %v", env, got, want)
+		}
+	}
+}
+
+func TestSignalExitCode(t *testing.T) {
+	if got := signalExitCode(syscall.SIGINT); got != 130 {
+		t.Errorf("signalExitCode(SIGINT) = %d, want 130", got)
+	}
+	if got := signalExitCode(syscall.SIGTERM); got != 143 {
+		t.Errorf("signalExitCode(SIGTERM) = %d, want 143", got)
+	}
+	if got := signalExitCode(syscall.SIGHUP); got != 143 {
+		t.Errorf("signalExitCode(SIGHUP) = %d, want 143", got)
+	}
+}
+
+func TestRegisterCleanup_RunsInReverseOrder(t *testing.T) {
+	defer resetCleanupHooks()
+	resetCleanupHooks()
+
+	var order []int
+	RegisterCleanup(func(context.Context) error { order = append(order, 1); return nil })
+	RegisterCleanup(func(context.Context) error { order = append(order, 2); return nil })
+	RegisterCleanup(func(context.Context) error { order = append(order, 3); return nil })
+
+	runCleanupHooks(context.Background())
+
+	want := []int{3, 2, 1}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestRunCleanupHooks_OneFailureDoesNotBlockOthers(t *testing.T) {
+	defer resetCleanupHooks()
+	resetCleanupHooks()
+
+	ran := false
+	RegisterCleanup(func(context.Context) error { ran = true; return nil })
+	RegisterCleanup(func(context.Context) error { return errors.New("boom") })
+
+	runCleanupHooks(context.Background())
+
+	if !ran {
+		t.Fatal("expected hook after the failing one to still run")
+	}
+}
+
+func TestAwaitGracefulShutdown_NoopWithoutSignal(t *testing.T) {
+	defer resetCleanupHooks()
+	resetCleanupHooks()
+
+	ran := false
+	RegisterCleanup(func(context.Context) error { ran = true; return nil })
+
+	awaitGracefulShutdown(context.Background())
+
+	if ran {
+		t.Fatal("expected no cleanup hooks to run for a non-cancelled context")
+	}
+}
+
+func TestAwaitGracefulShutdown_RunsHooksOnCancel(t *testing.T) {
+	defer resetCleanupHooks()
+	resetCleanupHooks()
+
+	ran := false
+	RegisterCleanup(func(context.Context) error { ran = true; return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	awaitGracefulShutdown(ctx)
+
+	if !ran {
+		t.Fatal("expected cleanup hooks to run for a cancelled context")
+	}
+}
+
+func TestNewShutdownContext_CancelsOnSignal(t *testing.T) {
+	ctx, exitCode, stop := newShutdownContext(context.Background())
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected context to be cancelled after SIGTERM")
+	}
+
+	if got := exitCode(); got != 143 {
+		t.Errorf("exitCode() = %d, want 143", got)
+	}
+}
+
+func TestDeath_WaitForDeathKillsChildAndDrainsLogger(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	logger, err := NewLogger()
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	logger.Info("before death")
+
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("cmd.Start() error = %v", err)
+	}
+
+	exited := make(chan struct{})
+	waitErrCh := make(chan error, 1)
+	go func() {
+		waitErrCh <- cmd.Wait()
+		close(exited)
+	}()
+
+	d := NewDeath(2*time.Second, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		d.WaitForDeath(logger, cmd, exited)
+		close(done)
+	}()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("WaitForDeath did not return after SIGTERM")
+	}
+
+	waitErr := <-waitErrCh
+	if waitErr == nil {
+		t.Fatal("expected child process to be killed, but it exited cleanly")
+	}
+
+	data, err := os.ReadFile(logger.Path())
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "before death") {
+		t.Fatalf("expected logger to be drained before WaitForDeath returned, content: %s", data)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// taskStateRecord is one line of a --state checkpoint file: a task's
+// resolved status as of the last layer boundary Run reached, enough for
+// --resume to decide whether to skip, re-run, or refuse to continue.
+type taskStateRecord struct {
+	TaskID    string `json:"task_id"`
+	Status    string `json:"status"` // pending|running|succeeded|failed|skipped
+	SessionID string `json:"session_id,omitempty"`
+	Layer     int    `json:"layer"`
+	Hash      string `json:"hash"`
+}
+
+// taskHash fingerprints the parts of a TaskSpec that matter for resuming:
+// its identity, its content, and the dependency edges that shaped its
+// layer. A --resume run refuses to treat a "succeeded" record as still
+// valid once any of these changed underneath it.
+func taskHash(t TaskSpec) string {
+	sum := sha256.Sum256([]byte(t.ID + "\x00" + t.Task + "\x00" + strings.Join(t.Dependencies, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// persistState checkpoints a full state.json snapshot: every task in layers
+// gets a taskStateRecord derived from results (if it's already finished),
+// running (if its layer just launched), or "pending" otherwise. A no-op
+// when s.statePath is unset.
+func (s *Scheduler) persistState(layerIdx int, layers [][]TaskSpec, results []TaskResult, running []TaskSpec) {
+	if s.statePath == "" {
+		return
+	}
+
+	byID := make(map[string]TaskResult, len(results))
+	for _, res := range results {
+		byID[res.TaskID] = res
+	}
+	isRunning := make(map[string]bool, len(running))
+	for _, t := range running {
+		isRunning[t.ID] = true
+	}
+
+	records := make(map[string]taskStateRecord)
+	for idx, layer := range layers {
+		for _, t := range layer {
+			rec := taskStateRecord{TaskID: t.ID, Layer: idx, Hash: taskHash(t), Status: "pending"}
+			if res, ok := byID[t.ID]; ok {
+				rec.Status = taskStatus(res)
+				rec.SessionID = res.SessionID
+			} else if idx == layerIdx && isRunning[t.ID] {
+				rec.Status = "running"
+			}
+			records[t.ID] = rec
+		}
+	}
+
+	if err := writeStateFile(s.statePath, records); err != nil {
+		logWarn(fmt.Sprintf("--state checkpoint to %s failed: %v", s.statePath, err))
+	}
+}
+
+// writeStateFile renders state as one JSON line per task, sorted by task ID
+// for a stable diff between checkpoints, fully replacing any prior content
+// at path.
+func writeStateFile(path string, state map[string]taskStateRecord) error {
+	ids := make([]string, 0, len(state))
+	for id := range state {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	for _, id := range ids {
+		if err := enc.Encode(state[id]); err != nil {
+			return fmt.Errorf("state: encode %s: %w", id, err)
+		}
+	}
+
+	return os.WriteFile(path, []byte(buf.String()), 0o644)
+}
+
+// loadStateFile reads a --state checkpoint written by writeStateFile. A
+// line that fails to parse - e.g. the file was read mid-write and its last
+// line is torn - is dropped rather than failing the whole load, so a crash
+// during checkpointing doesn't also break --resume.
+func loadStateFile(path string) (map[string]taskStateRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	state := make(map[string]taskStateRecord)
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec taskStateRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			continue
+		}
+		state[rec.TaskID] = rec
+	}
+
+	return state, nil
+}
+
+// buildResumePlan compares tasks against a previously loaded state: a task
+// recorded "succeeded" with a still-matching hash is carried forward as a
+// cached result instead of being re-run; anything else (pending, running,
+// failed, skipped, or simply missing from state) re-runs normally, and
+// shouldSkipTask naturally re-evaluates failed-downstream tasks against
+// this run's fresh results. A "succeeded" record whose hash no longer
+// matches the current task is spec drift - refused outright rather than
+// silently resumed against a result that no longer applies.
+func buildResumePlan(tasks []TaskSpec, state map[string]taskStateRecord) (map[string]TaskResult, error) {
+	preCompleted := make(map[string]TaskResult)
+	for _, t := range tasks {
+		rec, ok := state[t.ID]
+		if !ok || rec.Status != "succeeded" {
+			continue
+		}
+		if rec.Hash != taskHash(t) {
+			return nil, fmt.Errorf("resume: task %q changed since the checkpointed run (spec hash mismatch), refusing to resume", t.ID)
+		}
+		preCompleted[t.ID] = TaskResult{TaskID: t.ID, ExitCode: 0, SessionID: rec.SessionID}
+	}
+	return preCompleted, nil
+}
+
+// newConfiguredScheduler builds a Scheduler for layers wired up with every
+// --parallel/--pipeline knob cfg carries: fail-fast, shutdown grace, and (if
+// set) --state checkpointing and --resume. Shared by both call sites in
+// main.go so neither one can drift from the other.
+func newConfiguredScheduler(layers [][]TaskSpec, cfg *Config) (*Scheduler, error) {
+	sched := newScheduler(layers, cfg.Timeout).WithFailFast(cfg.FailFast).WithShutdownGrace(cfg.ShutdownGrace)
+
+	if cfg.StatePath != "" {
+		sched.WithStatePath(cfg.StatePath)
+	}
+
+	if cfg.ResumePath != "" {
+		state, err := loadStateFile(cfg.ResumePath)
+		if err != nil {
+			return nil, fmt.Errorf("--resume: failed to read %s: %w", cfg.ResumePath, err)
+		}
+		var allTasks []TaskSpec
+		for _, layer := range layers {
+			allTasks = append(allTasks, layer...)
+		}
+		pre, err := buildResumePlan(allTasks, state)
+		if err != nil {
+			return nil, err
+		}
+		sched.WithPreCompleted(pre)
+	}
+
+	return sched, nil
+}
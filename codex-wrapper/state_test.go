@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScheduler_InterruptedMidLayer2ResumesOnlyIncompleteLayer(t *testing.T) {
+	defer resetTestHooks()
+
+	layers := [][]TaskSpec{
+		{{ID: "l1a"}, {ID: "l1b"}},
+		{{ID: "l2a", Dependencies: []string{"l1a"}}, {ID: "l2b", Dependencies: []string{"l1b"}}},
+	}
+
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+
+	// First run: layer 1 fully succeeds, layer 2 never gets checkpointed as
+	// succeeded (simulating a crash mid-layer-2 by only checkpointing layer
+	// 1's terminal state, then the process "dying").
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		return TaskResult{TaskID: task.ID, ExitCode: 0, SessionID: "sess-" + task.ID}
+	}
+	sched := newScheduler(layers[:1], 5).WithStatePath(statePath)
+	sched.Run(context.Background())
+
+	if _, err := os.Stat(statePath); err != nil {
+		t.Fatalf("expected a state file after layer 1: %v", err)
+	}
+
+	// Resume: layer 1 should come back as cached (no invocation), layer 2
+	// should actually run.
+	invoked := make(map[string]bool)
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		invoked[task.ID] = true
+		return TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	cfg := &Config{Timeout: 5, ResumePath: statePath}
+	resumed, err := newConfiguredScheduler(layers, cfg)
+	if err != nil {
+		t.Fatalf("newConfiguredScheduler() error = %v", err)
+	}
+	results := resumed.Run(context.Background())
+
+	if invoked["l1a"] || invoked["l1b"] {
+		t.Fatalf("layer 1 tasks should not re-run after resume, invoked=%+v", invoked)
+	}
+	if !invoked["l2a"] || !invoked["l2b"] {
+		t.Fatalf("layer 2 tasks should run after resume, invoked=%+v", invoked)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results (2 cached + 2 re-run), got %d: %+v", len(results), results)
+	}
+}
+
+func TestBuildResumePlan_HashMismatchRefusesResume(t *testing.T) {
+	state := map[string]taskStateRecord{
+		"t1": {TaskID: "t1", Status: "succeeded", Hash: "stale-hash"},
+	}
+	tasks := []TaskSpec{{ID: "t1", Task: "echo changed"}}
+
+	_, err := buildResumePlan(tasks, state)
+	if err == nil {
+		t.Fatal("expected an error when a succeeded task's hash no longer matches")
+	}
+}
+
+func TestBuildResumePlan_MatchingHashCarriesResultForward(t *testing.T) {
+	task := TaskSpec{ID: "t1", Task: "echo hi", Dependencies: []string{"dep"}}
+	state := map[string]taskStateRecord{
+		"t1": {TaskID: "t1", Status: "succeeded", Hash: taskHash(task), SessionID: "sess-1"},
+	}
+
+	pre, err := buildResumePlan([]TaskSpec{task}, state)
+	if err != nil {
+		t.Fatalf("buildResumePlan() error = %v", err)
+	}
+	res, ok := pre["t1"]
+	if !ok || res.ExitCode != 0 || res.SessionID != "sess-1" {
+		t.Fatalf("expected t1 carried forward with session id, got %+v (ok=%v)", res, ok)
+	}
+}
+
+func TestLoadStateFile_TruncatedTrailingLineTolerated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	content := `{"task_id":"a","status":"succeeded","layer":0,"hash":"h-a"}
+{"task_id":"b","status":"failed","layer":0,"hash":"h-b"}
+{"task_id":"c","status":"succ`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	state, err := loadStateFile(path)
+	if err != nil {
+		t.Fatalf("loadStateFile() error = %v", err)
+	}
+	if len(state) != 2 {
+		t.Fatalf("expected the 2 complete lines to survive and the torn one dropped, got %+v", state)
+	}
+	if state["a"].Status != "succeeded" || state["b"].Status != "failed" {
+		t.Fatalf("unexpected state contents: %+v", state)
+	}
+	if _, ok := state["c"]; ok {
+		t.Fatalf("expected the torn trailing line for %q to be dropped", "c")
+	}
+}
+
+func TestScheduler_ResumeMergesResultsInOriginalLayerOrder(t *testing.T) {
+	defer resetTestHooks()
+
+	taskA := TaskSpec{ID: "a"}
+	taskB := TaskSpec{ID: "b", Dependencies: []string{"a"}}
+	layers := [][]TaskSpec{{taskA}, {taskB}}
+
+	state := map[string]taskStateRecord{
+		"a": {TaskID: "a", Status: "succeeded", Hash: taskHash(taskA), SessionID: "sess-a"},
+	}
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	if err := writeStateFile(statePath, state); err != nil {
+		t.Fatalf("writeStateFile() error = %v", err)
+	}
+
+	runCodexTaskFn = func(ctx context.Context, task TaskSpec, timeout int) TaskResult {
+		return TaskResult{TaskID: task.ID, ExitCode: 0}
+	}
+
+	cfg := &Config{Timeout: 5, ResumePath: statePath}
+	sched, err := newConfiguredScheduler(layers, cfg)
+	if err != nil {
+		t.Fatalf("newConfiguredScheduler() error = %v", err)
+	}
+	results := sched.Run(context.Background())
+
+	if len(results) != 2 || results[0].TaskID != "a" || results[1].TaskID != "b" {
+		t.Fatalf("expected results in original layer order [a, b], got %+v", results)
+	}
+	if results[0].SessionID != "sess-a" {
+		t.Fatalf("expected cached result for 'a' to carry its recorded session id, got %+v", results[0])
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// StreamParser extracts the final agent message and thread ID from a codex
+// subprocess's stdout. Implementations vary in how the bytes are framed:
+// NDJSON, SSE, or plain text.
+type StreamParser interface {
+	Parse(r io.Reader, logger *Logger) (message, threadID string)
+}
+
+// NDJSONParser parses newline-delimited JSON codex events, as produced by
+// `codex --json`. It's a thin wrapper around parseJSONStreamWithLogger so
+// both entry points share one implementation.
+type NDJSONParser struct{}
+
+// Parse implements StreamParser.
+func (NDJSONParser) Parse(r io.Reader, logger *Logger) (message, threadID string) {
+	return parseJSONStreamWithLogger(r, logger)
+}
+
+// SSEParser parses Server-Sent-Events framed output: multi-line `data:`
+// blocks accumulated until a blank line, then unmarshalled as one JSONEvent
+// through the same thread.started/item.completed handling as NDJSONParser.
+type SSEParser struct{}
+
+// Parse implements StreamParser.
+func (SSEParser) Parse(r io.Reader, logger *Logger) (message, threadID string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var dataLines []string
+	totalEvents := 0
+
+	flush := func() {
+		if len(dataLines) == 0 {
+			return
+		}
+		payload := strings.Join(dataLines, "\n")
+		dataLines = nil
+		totalEvents++
+		evLogger := logger.New("event_seq", totalEvents)
+
+		var event JSONEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			evLogger.Warn("Failed to parse SSE data block", "snippet", truncate(payload, 100))
+			return
+		}
+		if event.ThreadID != "" {
+			threadID = event.ThreadID
+		}
+		evLogger = evLogger.New("event_type", event.Type)
+		if threadID != "" {
+			evLogger = evLogger.New("thread_id", threadID)
+		}
+
+		switch event.Type {
+		case "thread.started":
+			evLogger.Info("thread.started event")
+		case "item.completed":
+			var itemType, normalized string
+			if event.Item != nil {
+				itemType = event.Item.Type
+				normalized = normalizeText(event.Item.Text)
+			}
+			evLogger.Info("item.completed event", "item_type", itemType, "message_len", len(normalized))
+			if itemType == "agent_message" && normalized != "" {
+				message = normalized
+			}
+		}
+	}
+
+	for scanner.Scan() {
+		trimmed := strings.TrimSpace(scanner.Text())
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "data:"):
+			dataLines = append(dataLines, strings.TrimSpace(strings.TrimPrefix(trimmed, "data:")))
+		case strings.HasPrefix(trimmed, "event:"), strings.HasPrefix(trimmed, ":"):
+			// SSE event-name and comment lines carry no JSON payload for us.
+		}
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		logger.New("event_seq", totalEvents).Warn("Read stdout error", "error", err.Error())
+	}
+
+	return message, threadID
+}
+
+// PlainTextParser treats the entire stream as the agent's message, for
+// codex invocations that emit neither NDJSON nor SSE.
+type PlainTextParser struct{}
+
+// Parse implements StreamParser.
+func (PlainTextParser) Parse(r io.Reader, logger *Logger) (message, threadID string) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		logger.Warn("Read stdout error", "error", err.Error())
+	}
+	return strings.TrimSpace(string(data)), ""
+}
+
+// DetectingParser sniffs the first non-empty line of the stream to pick
+// NDJSONParser, SSEParser or PlainTextParser, then commits to that parser
+// for the rest of the stream.
+type DetectingParser struct{}
+
+// Parse implements StreamParser.
+func (DetectingParser) Parse(r io.Reader, logger *Logger) (message, threadID string) {
+	br := bufio.NewReader(r)
+
+	var consumed strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		consumed.WriteString(line)
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			replayed := io.MultiReader(strings.NewReader(consumed.String()), br)
+			return detectParser(trimmed).Parse(replayed, logger)
+		}
+		if err != nil {
+			return "", ""
+		}
+	}
+}
+
+// detectParser maps a stream's first non-empty line to the parser that
+// should handle it: a leading '{' is NDJSON, an "event:"/"data:"/":" prefix
+// is SSE, anything else is treated as plain text.
+func detectParser(firstLine string) StreamParser {
+	switch {
+	case strings.HasPrefix(firstLine, "{"):
+		return NDJSONParser{}
+	case strings.HasPrefix(firstLine, "event:"), strings.HasPrefix(firstLine, "data:"), strings.HasPrefix(firstLine, ":"):
+		return SSEParser{}
+	default:
+		return PlainTextParser{}
+	}
+}
+
+// streamParserFromEnv reads CODEX_STREAM_FORMAT (auto|ndjson|sse|text,
+// case-insensitive) and returns the matching StreamParser, defaulting to
+// DetectingParser ("auto") when unset or unrecognized.
+func streamParserFromEnv() StreamParser {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("CODEX_STREAM_FORMAT"))) {
+	case "ndjson":
+		return NDJSONParser{}
+	case "sse":
+		return SSEParser{}
+	case "text":
+		return PlainTextParser{}
+	default:
+		return DetectingParser{}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNDJSONParser(t *testing.T) {
+	input := `{"type":"thread.started","thread_id":"tid-nd"}
+{"type":"item.completed","item":{"type":"agent_message","text":"hello"}}`
+
+	message, threadID := NDJSONParser{}.Parse(strings.NewReader(input), nil)
+	if message != "hello" || threadID != "tid-nd" {
+		t.Fatalf("message=%q threadID=%q, want hello/tid-nd", message, threadID)
+	}
+}
+
+func TestSSEParser(t *testing.T) {
+	input := "event: message\n" +
+		`data: {"type":"thread.started","thread_id":"tid-sse"}` + "\n\n" +
+		`data: {"type":"item.completed","item":{"type":"agent_message",` + "\n" +
+		`data: "text":"hello sse"}}` + "\n\n"
+
+	message, threadID := SSEParser{}.Parse(strings.NewReader(input), nil)
+	if message != "hello sse" || threadID != "tid-sse" {
+		t.Fatalf("message=%q threadID=%q, want %q/%q", message, threadID, "hello sse", "tid-sse")
+	}
+}
+
+func TestSSEParser_InvalidBlockDoesNotBreakStream(t *testing.T) {
+	input := "data: not-json\n\n" +
+		`data: {"type":"item.completed","item":{"type":"agent_message","text":"ok"}}` + "\n\n"
+
+	message, _ := SSEParser{}.Parse(strings.NewReader(input), nil)
+	if message != "ok" {
+		t.Fatalf("message = %q, want %q", message, "ok")
+	}
+}
+
+func TestPlainTextParser(t *testing.T) {
+	message, threadID := PlainTextParser{}.Parse(strings.NewReader("  hello plain text  \n"), nil)
+	if message != "hello plain text" || threadID != "" {
+		t.Fatalf("message=%q threadID=%q, want %q/empty", message, threadID, "hello plain text")
+	}
+}
+
+func TestDetectParser(t *testing.T) {
+	cases := []struct {
+		line string
+		want StreamParser
+	}{
+		{`{"type":"thread.started"}`, NDJSONParser{}},
+		{"event: message", SSEParser{}},
+		{"data: {}", SSEParser{}},
+		{": comment", SSEParser{}},
+		{"hello world", PlainTextParser{}},
+	}
+	for _, tc := range cases {
+		if got := detectParser(tc.line); got != tc.want {
+			t.Errorf("detectParser(%q) = %T, want %T", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestDetectingParser_NDJSON(t *testing.T) {
+	input := `{"type":"item.completed","item":{"type":"agent_message","text":"auto-nd"}}`
+	message, _ := DetectingParser{}.Parse(strings.NewReader(input), nil)
+	if message != "auto-nd" {
+		t.Fatalf("message = %q, want %q", message, "auto-nd")
+	}
+}
+
+func TestDetectingParser_SSE(t *testing.T) {
+	input := `data: {"type":"item.completed","item":{"type":"agent_message","text":"auto-sse"}}` + "\n\n"
+	message, _ := DetectingParser{}.Parse(strings.NewReader(input), nil)
+	if message != "auto-sse" {
+		t.Fatalf("message = %q, want %q", message, "auto-sse")
+	}
+}
+
+func TestDetectingParser_PlainText(t *testing.T) {
+	message, _ := DetectingParser{}.Parse(strings.NewReader("just plain output"), nil)
+	if message != "just plain output" {
+		t.Fatalf("message = %q, want %q", message, "just plain output")
+	}
+}
+
+func TestDetectingParser_SkipsLeadingBlankLines(t *testing.T) {
+	input := "\n\n" + `{"type":"item.completed","item":{"type":"agent_message","text":"after blanks"}}`
+	message, _ := DetectingParser{}.Parse(strings.NewReader(input), nil)
+	if message != "after blanks" {
+		t.Fatalf("message = %q, want %q", message, "after blanks")
+	}
+}
+
+func TestDetectingParser_EmptyInput(t *testing.T) {
+	message, threadID := DetectingParser{}.Parse(strings.NewReader(""), nil)
+	if message != "" || threadID != "" {
+		t.Fatalf("message=%q threadID=%q, want both empty", message, threadID)
+	}
+}
+
+func TestStreamParserFromEnv(t *testing.T) {
+	cases := []struct {
+		env  string
+		want StreamParser
+	}{
+		{"", DetectingParser{}},
+		{"auto", DetectingParser{}},
+		{"bogus", DetectingParser{}},
+		{"ndjson", NDJSONParser{}},
+		{"sse", SSEParser{}},
+		{"text", PlainTextParser{}},
+	}
+	for _, tc := range cases {
+		t.Setenv("CODEX_STREAM_FORMAT", tc.env)
+		if got := streamParserFromEnv(); got != tc.want {
+			t.Errorf("streamParserFromEnv() with CODEX_STREAM_FORMAT=%q = %T, want %T", tc.env, got, tc.want)
+		}
+	}
+}